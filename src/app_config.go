@@ -1,27 +1,151 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
-
-	logging "github.com/ipfs/go-log/v2"
+	"time"
 )
 
-func LoadEnv(log logging.EventLogger) AppConfig {
+// ConfigError is returned by LoadEnv for a missing or invalid environment variable.
+type ConfigError struct {
+	// Variable is the environment variable the error is about, empty for errors
+	// involving several variables.
+	Variable string
+	msg      string
+}
+
+func (e *ConfigError) Error() string {
+	return e.msg
+}
+
+// envChecker collects the configuration errors found while loading the environment.
+type envChecker struct {
+	errs []error
+}
+
+func (c *envChecker) fail(variable, format string, a ...interface{}) {
+	c.errs = append(c.errs, &ConfigError{Variable: variable, msg: fmt.Sprintf(format, a...)})
+}
+
+// LoadEnv loads the configuration from the environment. It returns the *ConfigError
+// of every missing or invalid variable, joined with errors.Join.
+func LoadEnv() (AppConfig, error) {
 	var config AppConfig
+	env := &envChecker{}
 
-	submissionStorage := getSubmissionStorage()
+	submissionStorage := getSubmissionStorage(env)
 
-	// delegation_verify bin path
-	delegationVerifyBinPath := getEnvChecked("DELEGATION_VERIFY_BIN_PATH", log)
-	noChecks := boolEnvChecked("NO_CHECKS", log)
-	networkName := getEnvChecked("NETWORK_NAME", log)
+	// verifier implementation, the fake one does not need the delegation_verify binary
+	verifier := strings.ToUpper(os.Getenv("DELEGATION_VERIFIER"))
+	if verifier == "" {
+		verifier = VerifierSubprocess
+	}
+	if err := validateVerifier(verifier); err != nil {
+		env.fail("DELEGATION_VERIFIER", "Error parsing DELEGATION_VERIFIER: %v", err)
+	}
+
+	// delegation_verify bin path, required by commands running the SUBPROCESS verifier (see startApp)
+	delegationVerifyBinPath := os.Getenv("DELEGATION_VERIFY_BIN_PATH")
+	// additional delegation_verify arguments, environment and working directory,
+	// arguments and environment entries are split like in a shell
+	verifierExtraArgs, err := splitArgs(os.Getenv("DELEGATION_VERIFY_EXTRA_ARGS"))
+	if err != nil {
+		env.fail("DELEGATION_VERIFY_EXTRA_ARGS", "Error parsing DELEGATION_VERIFY_EXTRA_ARGS: %v", err)
+	}
+	verifierEnv, err := splitArgs(os.Getenv("DELEGATION_VERIFY_ENV"))
+	if err != nil {
+		env.fail("DELEGATION_VERIFY_ENV", "Error parsing DELEGATION_VERIFY_ENV: %v", err)
+	}
+	for _, entry := range verifierEnv {
+		if !strings.Contains(entry, "=") || strings.HasPrefix(entry, "=") {
+			env.fail("DELEGATION_VERIFY_ENV", "Error parsing DELEGATION_VERIFY_ENV: %q is not a KEY=VALUE entry", entry)
+		}
+	}
+	// how to detect the delegation_verify version and which versions are accepted
+	verifierVersionArgsStr, ok := os.LookupEnv("DELEGATION_VERIFY_VERSION_ARGS")
+	if !ok {
+		verifierVersionArgsStr = "-version"
+	}
+	verifierVersionArgs, err := splitArgs(verifierVersionArgsStr)
+	if err != nil {
+		env.fail("DELEGATION_VERIFY_VERSION_ARGS", "Error parsing DELEGATION_VERIFY_VERSION_ARGS: %v", err)
+	}
+	verifierVersionPattern := os.Getenv("DELEGATION_VERIFY_VERSION_PATTERN")
+	if _, err := regexp.Compile(verifierVersionPattern); err != nil {
+		env.fail("DELEGATION_VERIFY_VERSION_PATTERN", "Error parsing DELEGATION_VERIFY_VERSION_PATTERN: %v", err)
+	}
+	storeVerifierVersion := boolEnvChecked("STORE_VERIFIER_VERSION", env)
+
+	// contract verifier results are checked against and what to do with non-conforming ones
+	verifierOutputContract := os.Getenv("DELEGATION_VERIFY_OUTPUT_CONTRACT")
+	if verifierOutputContract == "" {
+		verifierOutputContract = OutputContractNone
+	}
+	if _, err := getOutputContract(verifierOutputContract); err != nil {
+		env.fail("DELEGATION_VERIFY_OUTPUT_CONTRACT", "Error parsing DELEGATION_VERIFY_OUTPUT_CONTRACT: %v", err)
+	}
+	verifierOutputPolicy := strings.ToUpper(os.Getenv("DELEGATION_VERIFY_OUTPUT_POLICY"))
+	if verifierOutputPolicy == "" {
+		verifierOutputPolicy = OutputPolicyError
+	}
+	if err := validateOutputPolicy(verifierOutputPolicy); err != nil {
+		env.fail("DELEGATION_VERIFY_OUTPUT_POLICY", "Error parsing DELEGATION_VERIFY_OUTPUT_POLICY: %v", err)
+	}
+	verifierQuarantineFile := os.Getenv("DELEGATION_VERIFY_QUARANTINE_FILE")
+
+	verifierDir := os.Getenv("DELEGATION_VERIFY_WORKDIR")
+	if verifierDir != "" {
+		if info, err := os.Stat(verifierDir); err != nil || !info.IsDir() {
+			env.fail("DELEGATION_VERIFY_WORKDIR", "DELEGATION_VERIFY_WORKDIR %s is not a directory", verifierDir)
+		}
+	}
+	noChecks := boolEnvChecked("NO_CHECKS", env)
+	networkName := getEnvChecked("NETWORK_NAME", env)
 	genesisLedgerFile := os.Getenv("GENESIS_LEDGER_FILE")
 
+	// verification batch limits, 0 disables the limit
+	verifyBatchSize := intEnvChecked("VERIFY_BATCH_SIZE", 1000, env)
+	verifyBatchMaxBytes := intEnvChecked("VERIFY_BATCH_MAX_BYTES", 256*1024*1024, env)
+	verifyConcurrency := intEnvChecked("VERIFY_CONCURRENCY", runtime.NumCPU(), env)
+	if verifyConcurrency == 0 {
+		env.fail("VERIFY_CONCURRENCY", "VERIFY_CONCURRENCY, if set, should be at least 1")
+	}
+	// maximum number of verifier runs spent isolating failing submissions of a batch, 0 disables bisection
+	verifyBisectMaxRuns := intEnvChecked("VERIFY_BISECT_MAX_RUNS", 64, env)
+	// maximum run time of a single verifier process, 0 disables the timeout
+	verifyTimeout := durationEnvChecked("DELEGATION_VERIFY_TIMEOUT", time.Hour, env)
+
+	// what to do with submissions the verifier returned no result for
+	unreturnedPolicy := strings.ToUpper(os.Getenv("UNRETURNED_POLICY"))
+	if unreturnedPolicy == "" {
+		unreturnedPolicy = UnreturnedPolicyIgnore
+	}
+	if err := validateUnreturnedPolicy(unreturnedPolicy); err != nil {
+		env.fail("UNRETURNED_POLICY", "Error parsing UNRETURNED_POLICY: %v", err)
+	}
+
+	// file the run summary is written to, optional
+	runSummaryFile := os.Getenv("RUN_SUMMARY_FILE")
+
+	// follow mode configurations
+	followStateFile := os.Getenv("FOLLOW_STATE_FILE")
+	followWindow := durationEnvChecked("FOLLOW_WINDOW", 10*time.Minute, env)
+	followLateness := durationEnvChecked("FOLLOW_LATENESS", time.Minute, env)
+	// failed windows are retried with exponential backoff, 0 attempts retries forever
+	followRetryBackoff := durationEnvChecked("FOLLOW_RETRY_BACKOFF", time.Minute, env)
+	if followRetryBackoff == 0 {
+		// would retry a failing window in a tight loop
+		env.fail("FOLLOW_RETRY_BACKOFF", "FOLLOW_RETRY_BACKOFF, if set, should be greater than 0")
+	}
+	followMaxAttempts := intEnvChecked("FOLLOW_MAX_ATTEMPTS", 0, env)
+
 	// AWS configurations
-	bucketName := getEnvChecked("AWS_S3_BUCKET", log)
+	bucketName := getEnvChecked("AWS_S3_BUCKET", env)
 	awsRegion := os.Getenv("AWS_REGION")
 	// if webIdentityTokenFile, roleSessionName and roleArn are set,
 	// we are using AWS STS to assume a role and get temporary credentials
@@ -32,6 +156,60 @@ func LoadEnv(log logging.EventLogger) AppConfig {
 	// accessKeyId, secretAccessKey are not mandatory for production set up
 	accessKeyId := os.Getenv("AWS_ACCESS_KEY_ID")
 	secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	// S3-compatible endpoint (e.g. MinIO or LocalStack), TLS and static credentials, optional
+	s3EndpointURL := os.Getenv("S3_ENDPOINT_URL")
+	s3UsePathStyle := boolEnvChecked("S3_USE_PATH_STYLE", env)
+	s3InsecureSkipVerify := boolEnvChecked("S3_TLS_INSECURE_SKIP_VERIFY", env)
+	s3CABundle := os.Getenv("S3_CA_BUNDLE")
+	s3AccessKeyId := os.Getenv("S3_ACCESS_KEY_ID")
+	s3SecretAccessKey := os.Getenv("S3_SECRET_ACCESS_KEY")
+	s3SessionToken := os.Getenv("S3_SESSION_TOKEN")
+	// block fetch limits, shared by all block sources; the S3_* names are deprecated aliases
+	blockSourceConcurrency := intEnvChecked("BLOCK_SOURCE_CONCURRENCY", intEnvChecked("S3_DOWNLOAD_CONCURRENCY", 16, env), env)
+	if blockSourceConcurrency == 0 {
+		env.fail("BLOCK_SOURCE_CONCURRENCY", "BLOCK_SOURCE_CONCURRENCY, if set, should be at least 1")
+	}
+	blockSourceTimeout := durationEnvChecked("BLOCK_SOURCE_TIMEOUT", durationEnvChecked("S3_REQUEST_TIMEOUT", 30*time.Second, env), env)
+	blockSourceMaxRetries := intEnvChecked("BLOCK_SOURCE_MAX_RETRIES", intEnvChecked("S3_MAX_RETRIES", maxRetries, env), env)
+	if blockSourceMaxRetries == 0 {
+		env.fail("BLOCK_SOURCE_MAX_RETRIES", "BLOCK_SOURCE_MAX_RETRIES, if set, should be at least 1")
+	}
+	// number of objects read or written in parallel by the S3 submission storage
+	s3StoreConcurrency := intEnvChecked("S3_STORE_CONCURRENCY", 16, env)
+	if s3StoreConcurrency == 0 {
+		env.fail("S3_STORE_CONCURRENCY", "S3_STORE_CONCURRENCY, if set, should be at least 1")
+	}
+	// ordered chain of sources raw blocks are fetched from
+	blockSources := defaultBlockSources
+	if v := os.Getenv("BLOCK_SOURCES"); v != "" {
+		blockSources = nil
+		for _, spec := range strings.Split(v, ",") {
+			if spec = strings.TrimSpace(spec); spec != "" {
+				blockSources = append(blockSources, spec)
+			}
+		}
+	}
+	if err := validateBlockSources(blockSources); err != nil {
+		env.fail("BLOCK_SOURCES", "Error parsing BLOCK_SOURCES: %v", err)
+	}
+	// optional archive of submissions written before their payloads are dropped
+	archiveURL := os.Getenv("ARCHIVE_S3_URL")
+	var archiveBucket, archivePrefix string
+	if archiveURL != "" {
+		var err error
+		archiveBucket, archivePrefix, err = parseS3URL(archiveURL)
+		if err != nil {
+			env.fail("ARCHIVE_S3_URL", "Error parsing ARCHIVE_S3_URL: %v", err)
+		}
+	}
+	archiveConcurrency := intEnvChecked("ARCHIVE_CONCURRENCY", 16, env)
+	if archiveConcurrency == 0 {
+		env.fail("ARCHIVE_CONCURRENCY", "ARCHIVE_CONCURRENCY, if set, should be at least 1")
+	}
+	// optional on-disk block cache shared across runs
+	blockCacheDir := os.Getenv("BLOCK_CACHE_DIR")
+	blockCacheMaxBytes := int64EnvChecked("BLOCK_CACHE_MAX_BYTES", defaultBlockCacheMaxBytes, env)
+	blockCacheMaxAge := durationEnvChecked("BLOCK_CACHE_MAX_AGE", 7*24*time.Hour, env)
 
 	var awsKeyspace, cassandraHost, cassandraUsername, cassandraPassword, sslCertificatePath string
 	var cassandraPort, postgresPort int
@@ -51,7 +229,7 @@ func LoadEnv(log logging.EventLogger) AppConfig {
 		}
 		cassandraUsername = os.Getenv("CASSANDRA_USERNAME")
 		cassandraPassword = os.Getenv("CASSANDRA_PASSWORD")
-	} else {
+	} else if submissionStorage == "POSTGRES" {
 		// PostgreSQL configurations
 		postgresHost = os.Getenv("POSTGRES_HOST")
 		postgresUser = os.Getenv("POSTGRES_USER")
@@ -60,7 +238,7 @@ func LoadEnv(log logging.EventLogger) AppConfig {
 		var err error
 		postgresPort, err = strconv.Atoi(os.Getenv("POSTGRES_PORT"))
 		if err != nil {
-			log.Fatalf("Error parsing POSTGRES_PORT: %v", err)
+			env.fail("POSTGRES_PORT", "Error parsing POSTGRES_PORT: %v", err)
 		}
 		postgresSSLMode = os.Getenv("POSTGRES_SSLMODE")
 		if postgresSSLMode == "" {
@@ -70,9 +248,26 @@ func LoadEnv(log logging.EventLogger) AppConfig {
 	}
 
 	config.NetworkName = networkName
+	config.Verifier = verifier
 	config.DelegationVerifyBinPath = delegationVerifyBinPath
+	config.VerifierExtraArgs = verifierExtraArgs
+	config.VerifierEnv = verifierEnv
+	config.VerifierDir = verifierDir
+	config.VerifierVersionArgs = verifierVersionArgs
+	config.VerifierVersionPattern = verifierVersionPattern
+	config.StoreVerifierVersion = storeVerifierVersion
+	config.VerifierOutputContract = verifierOutputContract
+	config.VerifierOutputPolicy = verifierOutputPolicy
+	config.VerifierQuarantineFile = verifierQuarantineFile
 	config.NoChecks = noChecks
 	config.GenesisLedgerFile = genesisLedgerFile
+	config.VerifyBatchSize = verifyBatchSize
+	config.VerifyBatchMaxBytes = verifyBatchMaxBytes
+	config.VerifyConcurrency = verifyConcurrency
+	config.VerifyTimeout = verifyTimeout
+	config.VerifyBisectMaxRuns = verifyBisectMaxRuns
+	config.RunSummaryFile = runSummaryFile
+	config.UnreturnedPolicy = unreturnedPolicy
 	config.SubmissionStorage = submissionStorage
 	config.CassandraConfig = &CassandraConfig{
 		Keyspace:             awsKeyspace,
@@ -96,22 +291,55 @@ func LoadEnv(log logging.EventLogger) AppConfig {
 		DBName:   postgresDBName,
 		SSLMode:  postgresSSLMode,
 	}
+	config.FollowConfig = &FollowConfig{
+		StateFile: followStateFile,
+		Window:    followWindow,
+		Lateness:  followLateness,
+
+		RetryBackoff: followRetryBackoff,
+		MaxAttempts:  followMaxAttempts,
+	}
+	config.BlockSources = blockSources
+	config.BlockSourceConcurrency = blockSourceConcurrency
+	config.BlockSourceTimeout = blockSourceTimeout
+	config.BlockSourceMaxRetries = blockSourceMaxRetries
+	if archiveURL != "" {
+		config.ArchiveConfig = &ArchiveConfig{Bucket: archiveBucket, Prefix: archivePrefix, Concurrency: archiveConcurrency}
+	}
+	if blockCacheDir != "" {
+		config.BlockCacheConfig = &BlockCacheConfig{
+			Dir:      blockCacheDir,
+			MaxBytes: blockCacheMaxBytes,
+			MaxAge:   blockCacheMaxAge,
+		}
+	}
 	config.AwsConfig = &AwsConfig{
 		BucketName:      bucketName,
 		Region:          awsRegion,
 		AccessKeyId:     accessKeyId,
 		SecretAccessKey: secretAccessKey,
-	}
 
-	return config
-}
+		StoreConcurrency: s3StoreConcurrency,
 
-var validStorageOptions = map[string]bool{
-	"CASSANDRA": true,
-	"POSTGRES":  true,
+		EndpointURL:        s3EndpointURL,
+		UsePathStyle:       s3UsePathStyle,
+		InsecureSkipVerify: s3InsecureSkipVerify,
+		CABundle:           s3CABundle,
+		S3AccessKeyId:      s3AccessKeyId,
+		S3SecretAccessKey:  s3SecretAccessKey,
+		S3SessionToken:     s3SessionToken,
+	}
+	if err := validateS3Config(config.AwsConfig); err != nil {
+		env.fail("", "Error in S3 configuration: %v", err)
+	}
+
+	if err := errors.Join(env.errs...); err != nil {
+		return AppConfig{}, err
+	}
+	return config, nil
 }
 
-func getSubmissionStorage() string {
+func getSubmissionStorage(env *envChecker) string {
 	storage := os.Getenv("SUBMISSION_STORAGE")
 	if storage == "" {
 		storage = "POSTGRES" // Set default to "POSTGRES"
@@ -119,21 +347,21 @@ func getSubmissionStorage() string {
 	storage = strings.ToUpper(storage)
 
 	// Validate the storage option
-	if _, valid := validStorageOptions[storage]; !valid {
-		log.Fatalf("Invalid storage option: %s. Valid options are %v", storage, validStorageOptions)
+	if _, valid := submissionStores[storage]; !valid {
+		env.fail("SUBMISSION_STORAGE", "Invalid storage option: %s. Valid options are %v", storage, submissionStorageNames())
 	}
 	return storage
 }
 
-func getEnvChecked(variable string, log logging.EventLogger) string {
+func getEnvChecked(variable string, env *envChecker) string {
 	value := os.Getenv(variable)
 	if value == "" {
-		log.Fatalf("missing %s environment variable", variable)
+		env.fail(variable, "missing %s environment variable", variable)
 	}
 	return value
 }
 
-func boolEnvChecked(variable string, log logging.EventLogger) bool {
+func boolEnvChecked(variable string, env *envChecker) bool {
 	value := os.Getenv(variable)
 	switch value {
 	case "1":
@@ -143,16 +371,67 @@ func boolEnvChecked(variable string, log logging.EventLogger) bool {
 	case "":
 		return false
 	default:
-		log.Fatalf("%s, if set, should be either 0 or 1!", variable)
+		env.fail(variable, "%s, if set, should be either 0 or 1!", variable)
 		return false
 	}
 }
 
+func intEnvChecked(variable string, defaultValue int, env *envChecker) int {
+	value := os.Getenv(variable)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		env.fail(variable, "%s, if set, should be a non-negative integer: %v", variable, value)
+	}
+	return number
+}
+
+// int64EnvChecked is intEnvChecked for values that may not fit an int on 32-bit platforms, such as byte sizes.
+func int64EnvChecked(variable string, defaultValue int64, env *envChecker) int64 {
+	value := os.Getenv(variable)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		env.fail(variable, "%s, if set, should be a non-negative integer: %v", variable, value)
+	}
+	return number
+}
+
+func durationEnvChecked(variable string, defaultValue time.Duration, env *envChecker) time.Duration {
+	value := os.Getenv(variable)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		env.fail(variable, "%s, if set, should be a non-negative duration (e.g. 10m): %v", variable, value)
+	}
+	return duration
+}
+
 type AwsConfig struct {
 	BucketName      string `json:"bucket_name"`
 	Region          string `json:"region"`
 	AccessKeyId     string `json:"access_key_id"`
 	SecretAccessKey string `json:"secret_access_key"`
+	// StoreConcurrency is the number of objects the S3 submission storage reads or writes in parallel.
+	StoreConcurrency int `json:"store_concurrency"`
+
+	// EndpointURL replaces the AWS S3 endpoint, e.g. with a MinIO server.
+	EndpointURL        string `json:"endpoint_url,omitempty"`
+	UsePathStyle       bool   `json:"use_path_style"`
+	InsecureSkipVerify bool   `json:"tls_insecure_skip_verify"`
+	// CABundle is a PEM file of certificates trusted in addition to the system ones.
+	CABundle string `json:"ca_bundle,omitempty"`
+	// S3AccessKeyId, S3SecretAccessKey and S3SessionToken are static S3 credentials
+	// used instead of the default AWS credential chain.
+	S3AccessKeyId     string `json:"s3_access_key_id,omitempty"`
+	S3SecretAccessKey string `json:"s3_secret_access_key,omitempty"`
+	S3SessionToken    string `json:"s3_session_token,omitempty"`
 }
 
 type CassandraConfig struct {
@@ -179,13 +458,67 @@ type PostgreSQLConfig struct {
 	SSLMode  string `json:"sslmode"`
 }
 
+type FollowConfig struct {
+	StateFile string        `json:"state_file"`
+	Window    time.Duration `json:"window"`
+	Lateness  time.Duration `json:"lateness"`
+	// RetryBackoff is the delay before the first retry of a failed window, doubled for every further retry.
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	// MaxAttempts is the number of attempts after which a failing window is skipped, 0 retries forever.
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// ArchiveConfig configures the S3 location submissions are archived to before their
+// payloads are dropped from the store.
+type ArchiveConfig struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+	// Concurrency is the number of archive objects written in parallel.
+	Concurrency int `json:"concurrency"`
+}
+
+// BlockCacheConfig configures the on-disk block cache consulted before S3.
+// MaxBytes and MaxAge of 0 disable the respective eviction.
+type BlockCacheConfig struct {
+	Dir      string        `json:"dir"`
+	MaxBytes int64         `json:"max_bytes"`
+	MaxAge   time.Duration `json:"max_age"`
+}
+
 type AppConfig struct {
 	NetworkName             string            `json:"network_name"`
+	Verifier                string            `json:"verifier"`
 	DelegationVerifyBinPath string            `json:"delegation_verify_bin_path"`
+	VerifierExtraArgs       []string          `json:"verifier_extra_args,omitempty"`
+	VerifierEnv             []string          `json:"verifier_env,omitempty"`
+	VerifierDir             string            `json:"verifier_dir,omitempty"`
+	VerifierVersionArgs     []string          `json:"verifier_version_args"`
+	VerifierVersionPattern  string            `json:"verifier_version_pattern,omitempty"`
+	StoreVerifierVersion    bool              `json:"store_verifier_version"`
+	VerifierOutputContract  string            `json:"verifier_output_contract"`
+	VerifierOutputPolicy    string            `json:"verifier_output_policy"`
+	VerifierQuarantineFile  string            `json:"verifier_quarantine_file,omitempty"`
 	NoChecks                bool              `json:"no_checks"`
 	GenesisLedgerFile       string            `json:"genesis_ledger_file"`
+	VerifyBatchSize         int               `json:"verify_batch_size"`
+	VerifyBatchMaxBytes     int               `json:"verify_batch_max_bytes"`
+	VerifyConcurrency       int               `json:"verify_concurrency"`
+	VerifyTimeout           time.Duration     `json:"verify_timeout"`
+	VerifyBisectMaxRuns     int               `json:"verify_bisect_max_runs"`
+	RunSummaryFile          string            `json:"run_summary_file,omitempty"`
+	UnreturnedPolicy        string            `json:"unreturned_policy"`
+	DryRun                  bool              `json:"dry_run"`
+	Output                  string            `json:"output,omitempty"`
+	OutputFormat            string            `json:"output_format,omitempty"`
 	SubmissionStorage       string            `json:"submission_storage"`
 	AwsConfig               *AwsConfig        `json:"aws"`
 	CassandraConfig         *CassandraConfig  `json:"cassandra_config,omitempty"`
 	PostgreSQLConfig        *PostgreSQLConfig `json:"postgres_config,omitempty"`
+	FollowConfig            *FollowConfig     `json:"follow_config,omitempty"`
+	BlockSources            []string          `json:"block_sources"`
+	BlockSourceConcurrency  int               `json:"block_source_concurrency"`
+	BlockSourceTimeout      time.Duration     `json:"block_source_timeout"`
+	BlockSourceMaxRetries   int               `json:"block_source_max_retries"`
+	BlockCacheConfig        *BlockCacheConfig `json:"block_cache_config,omitempty"`
+	ArchiveConfig           *ArchiveConfig    `json:"archive_config,omitempty"`
 }
@@ -2,64 +2,92 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/gocql/gocql"
 	logging "github.com/ipfs/go-log/v2"
 )
 
 // AppContext holds shared resources and configurations.
 type AppContext struct {
-	CassandraSession *gocql.Session
-	PostgresSession  *sql.DB
-	S3Session        *s3.Client
-	AppConfig        AppConfig
-	Log              *logging.ZapEventLogger
+	Store    SubmissionStore
+	Verifier Verifier
+	// VerifierVersion is the detected version of Verifier, empty if unknown.
+	VerifierVersion string
+	S3Session       *s3.Client
+	// BlockSources are tried in order for submissions without raw block.
+	BlockSources []BlockSource
+	// BlockCache is consulted before BlockSources, nil if disabled.
+	BlockCache *DiskBlockCache
+	// Archiver archives submissions before the store is updated, nil if disabled.
+	Archiver  *S3Archiver
+	AppConfig AppConfig
+	Log       *logging.ZapEventLogger
 }
 
 // NewAppContext creates a new context with the necessary components.
 func NewAppContext(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (*AppContext, error) {
-	var cassandraSession *gocql.Session
-	var postgresSession *sql.DB
-	var err error
-	if config.SubmissionStorage == "CASSANDRA" {
-		cassandraSession, err = InitializeCassandraSession(config.CassandraConfig)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		postgresSession, err = InitializePostgresSession(config.PostgreSQLConfig)
+	verifier, err := NewVerifier(config, log)
+	if err != nil {
+		return nil, err
+	}
+	verifierVersion, err := detectVerifierVersion(ctx, verifier, config, log)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewSubmissionStore(ctx, config, log)
+	if err != nil {
+		return nil, err
+	}
+
+	s3Session, err := InitializeS3Session(ctx, config.AwsConfig)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	blockSources, err := newBlockSources(config, s3Session)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	var blockCache *DiskBlockCache
+	if cfg := config.BlockCacheConfig; cfg != nil {
+		blockCache, err = NewDiskBlockCache(cfg.Dir, cfg.MaxBytes, cfg.MaxAge, log)
 		if err != nil {
+			store.Close()
 			return nil, err
 		}
 	}
 
-	s3Session, err := InitializeS3Session(ctx, config.AwsConfig.Region)
-	if err != nil {
-		return nil, err
+	var archiver *S3Archiver
+	if cfg := config.ArchiveConfig; cfg != nil {
+		archiver = &S3Archiver{
+			Client:      s3Session,
+			Bucket:      cfg.Bucket,
+			Prefix:      cfg.Prefix,
+			NetworkName: config.NetworkName,
+			RawBlocks:   config.SubmissionStorage == "CASSANDRA",
+			Concurrency: cfg.Concurrency,
+			Log:         log,
+		}
 	}
 
 	return &AppContext{
-		CassandraSession: cassandraSession,
-		PostgresSession:  postgresSession,
-		Log:              log,
-		S3Session:        s3Session,
-		AppConfig:        config,
+		Store:           store,
+		Verifier:        verifier,
+		VerifierVersion: verifierVersion,
+		Log:             log,
+		S3Session:       s3Session,
+		BlockSources:    blockSources,
+		BlockCache:      blockCache,
+		Archiver:        archiver,
+		AppConfig:       config,
 	}, nil
 }
 
-func (ctx *AppContext) selectRange(startTime, endTime time.Time) ([]Submission, error) {
-	if ctx.AppConfig.SubmissionStorage == "CASSANDRA" {
-		return ctx.selectRangeCassandra(startTime, endTime)
-	}
-	return ctx.selectRangePostgres(startTime, endTime)
-}
-
-func (ctx *AppContext) updateSubmissions(submissions []Submission) error {
-	if ctx.AppConfig.SubmissionStorage == "CASSANDRA" {
-		return ctx.updateSubmissionsCassandra(submissions)
-	}
-	return ctx.updateSubmissionsPostgres(submissions)
+// Close releases resources held by the context.
+func (ctx *AppContext) Close() error {
+	return ctx.Store.Close()
 }
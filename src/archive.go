@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// ArchiveRecord is the content of an archive object: a submission as selected from the
+// store, including the payloads the store drops, and its verification outcome.
+type ArchiveRecord struct {
+	Submission Submission    `json:"submission"`
+	Outcome    ArchiveResult `json:"outcome"`
+	ArchivedAt time.Time     `json:"archived_at"`
+}
+
+// ArchiveResult is the verification outcome of an archived submission.
+type ArchiveResult struct {
+	StateHash       string `json:"state_hash"`
+	Parent          string `json:"parent"`
+	Height          int    `json:"height"`
+	Slot            int    `json:"slot"`
+	ValidationError string `json:"validation_error"`
+	Verified        bool   `json:"verified"`
+	VerifierVersion string `json:"verifier_version,omitempty"`
+}
+
+// S3Archiver writes gzip-compressed ArchiveRecords to
+// <Prefix><network>/<submitted_at_date>/<submitted_at>-<submitter>.json.gz objects,
+// before the store drops the payloads of verified submissions.
+type S3Archiver struct {
+	Client      *s3.Client
+	Bucket      string
+	Prefix      string
+	NetworkName string
+	// RawBlocks is set if the store drops raw blocks on update, which are then archived too.
+	// Snark work is always archived.
+	RawBlocks bool
+	// Concurrency is the number of objects written in parallel.
+	Concurrency int
+	Log         *logging.ZapEventLogger
+}
+
+// archivedPayloads are the payloads of a submission as selected from the store.
+type archivedPayloads struct {
+	RawBlock  RawBlock
+	SnarkWork []byte
+}
+
+// selectPayloads returns the payloads of submissions that the store drops on update, keyed
+// by submissionKey. It has to be called before addMissingBlocks replaces the raw blocks.
+func (a *S3Archiver) selectPayloads(submissions []Submission) map[string]archivedPayloads {
+	payloads := make(map[string]archivedPayloads, len(submissions))
+	for _, sub := range submissions {
+		p := archivedPayloads{SnarkWork: sub.SnarkWork}
+		if a.RawBlocks {
+			p.RawBlock = sub.RawBlock
+		}
+		payloads[submissionKey(sub)] = p
+	}
+	return payloads
+}
+
+// Archive writes an archive object for every verified submission, paired with the
+// submission of batch it was produced from and its payloads as returned by selectPayloads.
+// It only returns once all objects are written, an error means that some of them may be missing.
+func (a *S3Archiver) Archive(ctx context.Context, batch []Submission, payloads map[string]archivedPayloads, verifiedSubmissions []Submission) error {
+	originals := make(map[string]Submission, len(batch))
+	for _, sub := range batch {
+		originals[submissionKey(sub)] = sub
+	}
+
+	archivedAt := time.Now().UTC()
+	errs := make([]error, len(verifiedSubmissions))
+	forEachConcurrently(len(verifiedSubmissions), a.Concurrency, func(i int) {
+		result := verifiedSubmissions[i]
+		original, found := originals[submissionKey(result)]
+		if !found {
+			original = result
+		}
+		stored := payloads[submissionKey(result)]
+		original.RawBlock, original.SnarkWork = stored.RawBlock, stored.SnarkWork
+		record := ArchiveRecord{
+			Submission: original,
+			Outcome: ArchiveResult{
+				StateHash:       result.StateHash,
+				Parent:          result.Parent,
+				Height:          result.Height,
+				Slot:            result.Slot,
+				ValidationError: result.ValidationError,
+				Verified:        result.Verified,
+				VerifierVersion: result.VerifierVersion,
+			},
+			ArchivedAt: archivedAt,
+		}
+		key := a.key(original)
+		errs[i] = ExponentialBackoff(ctx, func() error {
+			if err := a.put(ctx, key, record); err != nil {
+				a.Log.Errorf("Error archiving submission to %s (trying again): %v", key, err)
+				return err
+			}
+			return nil
+		}, maxRetries, initialBackoff)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error archiving submissions: %w", err)
+	}
+	a.Log.Infof("Archived %d submissions to s3://%s/%s", len(verifiedSubmissions), a.Bucket, a.Prefix)
+	return nil
+}
+
+// key returns the key of the archive object of sub.
+func (a *S3Archiver) key(sub Submission) string {
+	date := sub.SubmittedAtDate
+	if date == "" {
+		date = sub.SubmittedAt.UTC().Format("2006-01-02")
+	}
+	return fmt.Sprintf("%s%s/%s/%s-%s.json.gz", a.Prefix, a.NetworkName, date,
+		sub.SubmittedAt.UTC().Format(time.RFC3339Nano), sub.Submitter)
+}
+
+func (a *S3Archiver) put(ctx context.Context, key string, record ArchiveRecord) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(record); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := a.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/gzip"),
+	}, singleAttempt)
+	return err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+func TestWriteBatchArchivesBeforeUpdate(t *testing.T) {
+	submittedAt := time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC)
+	batch := []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: submittedAt, Submitter: "B62a", BlockHash: "3NKa",
+			RawBlock: RawBlock("block"), SnarkWork: []byte("snark")},
+	}
+	payloads := map[string]archivedPayloads{"1": {RawBlock: RawBlock("block"), SnarkWork: []byte("snark")}}
+	verified := []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: submittedAt, Submitter: "B62a", BlockHash: "3NKa",
+			StateHash: "3NLa", Height: 7, Verified: true, VerifierVersion: "v1"},
+	}
+
+	t.Run("archived", func(t *testing.T) {
+		bucket := &fakeS3{bucket: "archive", objects: map[string][]byte{}}
+		server := httptest.NewServer(bucket)
+		defer server.Close()
+
+		store := &fakeStore{}
+		appCtx := newTestAppContext(store, AppConfig{})
+		appCtx.Archiver = &S3Archiver{Client: newTestS3Client(server), Bucket: "archive", Prefix: "payloads/", NetworkName: "testnet", Log: logging.Logger("test")}
+
+		if _, err := appCtx.writeBatch(context.Background(), batch, payloads, verified); err != nil {
+			t.Fatal(err)
+		}
+		if len(store.updated) != 1 {
+			t.Fatalf("store updated with %d submissions, want 1", len(store.updated))
+		}
+
+		record := readArchiveRecord(t, bucket, "payloads/testnet/2024-03-11/2024-03-11T10:00:00Z-B62a.json.gz")
+		if string(record.Submission.RawBlock) != "block" || string(record.Submission.SnarkWork) != "snark" {
+			t.Errorf("archived payloads = %q, %q, want original payloads", record.Submission.RawBlock, record.Submission.SnarkWork)
+		}
+		if !record.Outcome.Verified || record.Outcome.StateHash != "3NLa" || record.Outcome.Height != 7 || record.Outcome.VerifierVersion != "v1" {
+			t.Errorf("archived outcome = %+v", record.Outcome)
+		}
+	})
+
+	t.Run("archive failure", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		store := &fakeStore{}
+		appCtx := newTestAppContext(store, AppConfig{})
+		appCtx.Archiver = &S3Archiver{Client: newTestS3Client(server), Bucket: "archive", NetworkName: "testnet", Log: logging.Logger("test")}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := appCtx.writeBatch(ctx, batch, payloads, verified); err == nil {
+			t.Fatal("writeBatch() succeeded, want archive error")
+		}
+		if len(store.updated) != 0 {
+			t.Errorf("store updated with %d submissions although archiving failed", len(store.updated))
+		}
+	})
+}
+
+func readArchiveRecord(t *testing.T, bucket *fakeS3, key string) ArchiveRecord {
+	t.Helper()
+	data, ok := bucket.objects[key]
+	if !ok {
+		t.Fatalf("archive object not written, objects: %v", bucket.objects)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("archive object is not gzip-compressed: %v", err)
+	}
+	var record ArchiveRecord
+	if err := json.NewDecoder(zr).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestProcessSubmissionsArchivesStoredPayloads(t *testing.T) {
+	submittedAt := time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		rawBlocks bool
+		submitted Submission
+		rawBlock  string
+	}{
+		{
+			name:      "cassandra",
+			rawBlocks: true,
+			submitted: Submission{SubmittedAtDate: "2024-03-11", Shard: 3, SubmittedAt: submittedAt, Submitter: "B62a", BlockHash: "3NKa",
+				RawBlock: RawBlock("stored"), SnarkWork: []byte("snark")},
+			rawBlock: "stored",
+		},
+		{
+			name: "postgres",
+			submitted: Submission{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: submittedAt, Submitter: "B62a", BlockHash: "3NKa",
+				SnarkWork: []byte("snark")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("fetched"))
+			}))
+			defer blocks.Close()
+			bucket := &fakeS3{bucket: "archive", objects: map[string][]byte{}}
+			server := httptest.NewServer(bucket)
+			defer server.Close()
+
+			store := &fakeStore{}
+			appCtx := newTestAppContext(store, AppConfig{
+				NetworkName:             "testnet",
+				DelegationVerifyBinPath: writeEchoVerifier(t),
+				AwsConfig:               &AwsConfig{BucketName: "bucket"},
+				BlockSources:            []string{"S3"},
+				BlockSourceConcurrency:  1,
+				BlockSourceMaxRetries:   1,
+			})
+			appCtx.BlockSources = []BlockSource{newTestS3BlockSource(blocks)}
+			appCtx.Archiver = &S3Archiver{Client: newTestS3Client(server), Bucket: "archive", NetworkName: "testnet",
+				RawBlocks: tt.rawBlocks, Log: logging.Logger("test")}
+
+			err := appCtx.processSubmissions(context.Background(), []Submission{tt.submitted}, submittedAt, submittedAt.Add(time.Hour), nil)
+			if err != nil {
+				t.Fatalf("processSubmissions() error = %v", err)
+			}
+			if len(store.updated) != 1 {
+				t.Fatalf("store updated with %d submissions, want 1", len(store.updated))
+			}
+
+			record := readArchiveRecord(t, bucket, "testnet/2024-03-11/2024-03-11T10:00:00Z-B62a.json.gz")
+			if string(record.Submission.RawBlock) != tt.rawBlock || string(record.Submission.SnarkWork) != "snark" {
+				t.Errorf("archived payloads = %q, %q, want %q, %q", record.Submission.RawBlock, record.Submission.SnarkWork, tt.rawBlock, "snark")
+			}
+		})
+	}
+}
+
+func TestArchivePutSingleAttempt(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	archiver := &S3Archiver{Client: newRetryingTestS3Client(server), Bucket: "archive", NetworkName: "testnet", Log: logging.Logger("test")}
+
+	if err := archiver.put(context.Background(), "key.json.gz", ArchiveRecord{}); err == nil {
+		t.Fatal("put() succeeded, want error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("put() sent %d requests, want 1", n)
+	}
+}
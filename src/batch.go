@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// BatchResult records the outcome of verifying and updating a single batch of submissions.
+type BatchResult struct {
+	Index       int
+	Submissions int
+	// Verified and Invalid count the results written back that passed and failed verification.
+	Verified int
+	Invalid  int
+	Err      error
+}
+
+// splitIntoBatches splits submissions into consecutive batches holding at most maxCount
+// submissions and at most maxBytes bytes of raw blocks. A limit of 0 disables it.
+// A submission whose raw block alone exceeds maxBytes is put into a batch of its own.
+func splitIntoBatches(submissions []Submission, maxCount, maxBytes int) [][]Submission {
+	var batches [][]Submission
+	var current []Submission
+	currentBytes := 0
+
+	for _, sub := range submissions {
+		full := maxCount > 0 && len(current) >= maxCount
+		tooBig := maxBytes > 0 && len(current) > 0 && currentBytes+len(sub.RawBlock) > maxBytes
+		if full || tooBig {
+			batches = append(batches, current)
+			current = nil
+			currentBytes = 0
+		}
+		current = append(current, sub)
+		currentBytes += len(sub.RawBlock)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// processBatches runs delegation verification on batches using up to VerifyConcurrency
+// verifier processes at a time. Results are written back to the store in batch order
+// as soon as all preceding batches have been written.
+// payloads are the archived payloads of the submissions, nil if archiving is disabled.
+// If exporter is not nil, written submissions are also exported to it.
+// If progress is not nil, every written batch is recorded in it.
+// The outcome of every batch is recorded in summary.
+// Errors are recorded per batch so that remaining batches can still be processed.
+func (appCtx *AppContext) processBatches(ctx context.Context, batches [][]Submission, payloads map[string]archivedPayloads, exporter *ResultExporter, summary *RunSummary, progress *windowProgress) []BatchResult {
+	concurrency := appCtx.AppConfig.VerifyConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(batches) {
+		concurrency = len(batches)
+	}
+
+	type verification struct {
+		submissions []Submission
+		err         error
+	}
+	// Each batch gets its own buffered channel so that workers never block
+	// on batches that are written back out of order.
+	verifications := make([]chan verification, len(batches))
+	for i := range verifications {
+		verifications[i] = make(chan verification, 1)
+	}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range batches {
+			jobs <- i
+		}
+	}()
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					verifications[i] <- verification{err: fmt.Errorf("batch not verified: %w", err)}
+					continue
+				}
+				verifiedSubmissions, err := appCtx.verifyBatch(ctx, i, batches[i])
+				verifications[i] <- verification{submissions: verifiedSubmissions, err: err}
+			}
+		}()
+	}
+
+	results := make([]BatchResult, len(batches))
+	for i, batch := range batches {
+		v := <-verifications[i]
+		results[i] = BatchResult{Index: i, Submissions: len(batch)}
+		if v.err != nil {
+			results[i].Err = v.err
+			summary.addBatch(batch, nil, v.err)
+			continue
+		}
+		verifiedSubmissions, report := appCtx.reconcileBatch(i, batch, v.submissions)
+		for j := range verifiedSubmissions {
+			verifiedSubmissions[j].VerifierVersion = appCtx.VerifierVersion
+		}
+		summary.addReconcileReport(report)
+		results[i].Invalid, results[i].Err = appCtx.writeBatch(ctx, batch, payloads, verifiedSubmissions)
+		results[i].Verified = len(verifiedSubmissions) - results[i].Invalid
+		if results[i].Err == nil && progress != nil {
+			if err := progress.commit(batch); err != nil {
+				results[i].Err = fmt.Errorf("error saving follow progress: %w", err)
+			}
+		}
+		if results[i].Err == nil && exporter != nil {
+			if err := exporter.Write(verifiedSubmissions); err != nil {
+				results[i].Err = fmt.Errorf("error exporting results: %w", err)
+			}
+		}
+		summary.addBatch(batch, verifiedSubmissions, results[i].Err)
+	}
+	return results
+}
+
+// verifyOnce runs delegation verification on a single batch of submissions.
+// The verifier is killed if it runs longer than VerifyTimeout or ctx is cancelled.
+func (appCtx *AppContext) verifyOnce(ctx context.Context, batch []Submission) ([]Submission, error) {
+	if timeout := appCtx.AppConfig.VerifyTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	verifiedSubmissions, err := appCtx.Verifier.Verify(ctx, batch)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("delegation verifier timed out after %v: %w", appCtx.AppConfig.VerifyTimeout, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error verifying batch: %w", err)
+	}
+	return verifiedSubmissions, nil
+}
+
+// writeBatch writes verified submissions back to the store and logs the invalid ones.
+// If an archiver is configured, the submissions are archived first, with the payloads as
+// selected, and the store is only updated, dropping the payloads, once the archive is written.
+// In dry-run mode the store is left untouched and the would-be updates are reported instead.
+// It returns the number of invalid submissions.
+func (appCtx *AppContext) writeBatch(ctx context.Context, batch []Submission, payloads map[string]archivedPayloads, verifiedSubmissions []Submission) (int, error) {
+	if appCtx.AppConfig.DryRun {
+		appCtx.reportDryRun(batch, verifiedSubmissions)
+	} else {
+		if appCtx.Archiver != nil {
+			if err := appCtx.Archiver.Archive(ctx, batch, payloads, verifiedSubmissions); err != nil {
+				return 0, err
+			}
+		}
+		if err := appCtx.Store.UpdateSubmissions(ctx, verifiedSubmissions); err != nil {
+			return 0, fmt.Errorf("error updating submissions: %w", err)
+		}
+	}
+
+	invalid := 0
+	for _, sub := range verifiedSubmissions {
+		if sub.ValidationError != "" || !sub.Verified {
+			invalid++
+			appCtx.Log.Infof("[INVALID] Submitter: %s, Block hash: %s, Submitted at: %s, Validation error: %s, Verified: %v",
+				sub.Submitter, sub.BlockHash, sub.SubmittedAt, sub.ValidationError, sub.Verified)
+		}
+	}
+	return invalid, nil
+}
+
+// summarizeBatches logs the outcome of every batch and returns an error if any of them failed.
+func summarizeBatches(log *logging.ZapEventLogger, results []BatchResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			log.Errorf("Batch %d (%d submissions) failed: %v", result.Index, result.Submissions, result.Err)
+			continue
+		}
+		log.Infof("Batch %d: %d submissions, %d verified, %d invalid",
+			result.Index, result.Submissions, result.Verified, result.Invalid)
+	}
+	log.Infof("Processed %d batches: %d succeeded, %d failed", len(results), len(results)-failed, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d batches failed", failed, len(results))
+	}
+	return nil
+}
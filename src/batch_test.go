@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitIntoBatches(t *testing.T) {
+	withBlock := func(ids ...string) []Submission {
+		var submissions []Submission
+		for _, id := range ids {
+			submissions = append(submissions, Submission{ID: id, RawBlock: RawBlock("0123456789")})
+		}
+		return submissions
+	}
+	ids := func(batches [][]Submission) [][]string {
+		var result [][]string
+		for _, batch := range batches {
+			var batchIDs []string
+			for _, sub := range batch {
+				batchIDs = append(batchIDs, sub.ID)
+			}
+			result = append(result, batchIDs)
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name        string
+		submissions []Submission
+		maxCount    int
+		maxBytes    int
+		want        [][]string
+	}{
+		{
+			name:        "no submissions",
+			submissions: nil,
+			maxCount:    2,
+			want:        nil,
+		},
+		{
+			name:        "no limits",
+			submissions: withBlock("1", "2", "3"),
+			want:        [][]string{{"1", "2", "3"}},
+		},
+		{
+			name:        "by count",
+			submissions: withBlock("1", "2", "3"),
+			maxCount:    2,
+			want:        [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:        "by bytes",
+			submissions: withBlock("1", "2", "3"),
+			maxBytes:    25,
+			want:        [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:        "oversized submission",
+			submissions: withBlock("1", "2"),
+			maxBytes:    5,
+			want:        [][]string{{"1"}, {"2"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ids(splitIntoBatches(tc.submissions, tc.maxCount, tc.maxBytes))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitIntoBatches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
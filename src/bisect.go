@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// poisonValidationErrorPrefix starts the validation error of submissions the verifier fails on.
+const poisonValidationErrorPrefix = "delegation verifier failed on this submission: "
+
+// maxPoisonErrorLength truncates the verifier error stored with a poison submission.
+const maxPoisonErrorLength = 512
+
+// verifyBatch runs delegation verification on a batch. If the verifier fails, the batch
+// is bisected to isolate the submissions it fails on ("poison" submissions): halves are
+// verified separately until the failing submissions are found, which are then marked
+// invalid while the rest of the batch is verified normally.
+//
+// Bisection is not attempted for timeouts, cancellation and output contract violations, and gives up after
+// VerifyBisectMaxRuns verifier runs. If every submission of the batch fails on its own,
+// the verifier itself is assumed to be broken and the batch fails.
+func (appCtx *AppContext) verifyBatch(ctx context.Context, index int, batch []Submission) ([]Submission, error) {
+	results, err := appCtx.verifyOnce(ctx, batch)
+	if err == nil || !canBisect(ctx, err) || appCtx.AppConfig.VerifyBisectMaxRuns <= 0 || len(batch) == 1 {
+		return results, err
+	}
+
+	appCtx.Log.Warnf("Batch %d (%d submissions) failed, bisecting it to isolate failing submissions: %v", index, len(batch), err)
+	b := &bisector{appCtx: appCtx, maxRuns: appCtx.AppConfig.VerifyBisectMaxRuns}
+	mid := len(batch) / 2
+	left, bisectErr := b.verify(ctx, batch[:mid])
+	if bisectErr == nil {
+		var right []Submission
+		right, bisectErr = b.verify(ctx, batch[mid:])
+		left = append(left, right...)
+	}
+	if bisectErr != nil {
+		return nil, fmt.Errorf("%w (isolating failing submissions failed: %v)", err, bisectErr)
+	}
+	if len(b.poison) == len(batch) {
+		return nil, fmt.Errorf("%w (every submission fails on its own, not marking them)", err)
+	}
+
+	for _, sub := range b.poison {
+		appCtx.Log.Warnf("[POISON] Batch %d: delegation verifier fails on submission %s (submitter %s, block hash %s): %s",
+			index, submissionKey(sub), sub.Submitter, sub.BlockHash, sub.ValidationError)
+	}
+	appCtx.Log.Infof("Batch %d: isolated %d failing submissions in %d verifier runs", index, len(b.poison), b.runs)
+	return left, nil
+}
+
+// canBisect reports whether a verifier failure may be caused by the submissions of the batch.
+// Results not conforming to the output contract are caused by the verifier release instead.
+func canBisect(ctx context.Context, err error) bool {
+	var contractErr *OutputContractError
+	return ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &contractErr)
+}
+
+// bisector isolates the submissions of a failing batch the verifier fails on.
+type bisector struct {
+	appCtx  *AppContext
+	maxRuns int
+	runs    int
+	poison  []Submission
+}
+
+// verify verifies batch, splitting it in halves as long as the verifier fails on it.
+// Single failing submissions are returned marked as poison.
+func (b *bisector) verify(ctx context.Context, batch []Submission) ([]Submission, error) {
+	if b.runs >= b.maxRuns {
+		return nil, fmt.Errorf("gave up after %d verifier runs", b.runs)
+	}
+	b.runs++
+	results, err := b.appCtx.verifyOnce(ctx, batch)
+	if err == nil {
+		return results, nil
+	}
+	if !canBisect(ctx, err) {
+		return nil, err
+	}
+	if len(batch) == 1 {
+		poison := markPoison(batch[0], err)
+		b.poison = append(b.poison, poison)
+		return []Submission{poison}, nil
+	}
+
+	mid := len(batch) / 2
+	left, err := b.verify(ctx, batch[:mid])
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.verify(ctx, batch[mid:])
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// markPoison returns sub marked as not verified with the error the verifier failed with.
+func markPoison(sub Submission, err error) Submission {
+	msg := strings.Join(strings.Fields(err.Error()), " ")
+	if len(msg) > maxPoisonErrorLength {
+		cut := maxPoisonErrorLength
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = msg[:cut] + "..."
+	}
+	sub.Verified = false
+	sub.ValidationError = poisonValidationErrorPrefix + msg
+	sub.StateHash, sub.Parent, sub.Height, sub.Slot = "", "", 0, 0
+	return sub
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// crashingVerifier fails on every batch containing one of the poison IDs and
+// otherwise verifies all submissions.
+type crashingVerifier struct {
+	poison map[string]bool
+	runs   int
+}
+
+func (v *crashingVerifier) Verify(ctx context.Context, batch []Submission) ([]Submission, error) {
+	v.runs++
+	results := make([]Submission, 0, len(batch))
+	for _, sub := range batch {
+		if v.poison[sub.ID] {
+			return nil, errors.New("exit status 2, stderr:\nFatal error: malformed block")
+		}
+		sub.Verified = true
+		results = append(results, sub)
+	}
+	return results, nil
+}
+
+func (v *crashingVerifier) Version(ctx context.Context) (string, error) {
+	return "crashing", nil
+}
+
+func TestVerifyBatchBisect(t *testing.T) {
+	batch := make([]Submission, 10)
+	for i := range batch {
+		batch[i] = Submission{ID: fmt.Sprint(i)}
+	}
+
+	testCases := []struct {
+		name       string
+		poison     []string
+		maxRuns    int
+		wantPoison []string
+		wantErr    bool
+	}{
+		{name: "no failure", maxRuns: 64},
+		{name: "single poison", poison: []string{"3"}, maxRuns: 64, wantPoison: []string{"3"}},
+		{name: "two poisons", poison: []string{"0", "9"}, maxRuns: 64, wantPoison: []string{"0", "9"}},
+		{name: "bisection disabled", poison: []string{"3"}, maxRuns: 0, wantErr: true},
+		{name: "run limit", poison: []string{"3"}, maxRuns: 2, wantErr: true},
+		{name: "broken verifier", poison: []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, maxRuns: 64, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier := &crashingVerifier{poison: make(map[string]bool)}
+			for _, id := range tc.poison {
+				verifier.poison[id] = true
+			}
+			appCtx := &AppContext{
+				Verifier:  verifier,
+				AppConfig: AppConfig{VerifyBisectMaxRuns: tc.maxRuns},
+				Log:       logging.Logger("test"),
+			}
+
+			results, err := appCtx.verifyBatch(context.Background(), 0, batch)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("verifyBatch() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if len(results) != len(batch) {
+				t.Fatalf("verifyBatch() returned %d results, want %d", len(results), len(batch))
+			}
+			var gotPoison []string
+			for _, result := range results {
+				if strings.HasPrefix(result.ValidationError, poisonValidationErrorPrefix) {
+					gotPoison = append(gotPoison, result.ID)
+					if result.Verified || !strings.Contains(result.ValidationError, "Fatal error: malformed block") {
+						t.Errorf("poison submission %s = %+v, want not verified with the verifier error", result.ID, result)
+					}
+				} else if !result.Verified {
+					t.Errorf("submission %s not verified", result.ID)
+				}
+			}
+			if strings.Join(gotPoison, ",") != strings.Join(tc.wantPoison, ",") {
+				t.Errorf("verifyBatch() poison = %v, want %v", gotPoison, tc.wantPoison)
+			}
+		})
+	}
+}
+
+func TestVerifyBatchNoBisectOnCancel(t *testing.T) {
+	verifier := &crashingVerifier{poison: map[string]bool{"1": true}}
+	appCtx := &AppContext{
+		Verifier:  verifier,
+		AppConfig: AppConfig{VerifyBisectMaxRuns: 64},
+		Log:       logging.Logger("test"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := appCtx.verifyBatch(ctx, 0, []Submission{{ID: "1"}, {ID: "2"}}); err == nil {
+		t.Fatal("verifyBatch() error = nil, want error")
+	}
+	if verifier.runs != 1 {
+		t.Errorf("verifier ran %d times, want 1", verifier.runs)
+	}
+}
+
+func TestMarkPoisonTruncatesOnRuneBoundary(t *testing.T) {
+	// "é" is two bytes, so byte maxPoisonErrorLength falls in the middle of a rune.
+	msg := "x" + strings.Repeat("é", maxPoisonErrorLength)
+	sub := markPoison(Submission{ID: "1", Verified: true, StateHash: "3NK"}, errors.New(msg))
+
+	if !utf8.ValidString(sub.ValidationError) {
+		t.Errorf("ValidationError is not valid UTF-8: %q", sub.ValidationError)
+	}
+	want := poisonValidationErrorPrefix + msg[:maxPoisonErrorLength-1] + "..."
+	if sub.ValidationError != want {
+		t.Errorf("ValidationError = %q, want %q", sub.ValidationError, want)
+	}
+	if sub.Verified || sub.StateHash != "" {
+		t.Errorf("markPoison() = %+v, want not verified without state hash", sub)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// blockCacheHeaderPrefix starts the first line of a cache file, which holds the
+// SHA-256 checksum of the block following it.
+const blockCacheHeaderPrefix = "sha256:"
+
+// defaultBlockCacheMaxBytes is the default of BLOCK_CACHE_MAX_BYTES, 10 GiB.
+const defaultBlockCacheMaxBytes int64 = 10 * 1024 * 1024 * 1024
+
+// blockCacheKeyPattern matches the network names and block hashes used as cache keys,
+// which keeps them from escaping the cache directory.
+var blockCacheKeyPattern = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// DiskBlockCache is a persistent cache of raw blocks keyed by network and block hash,
+// shared by all runs using the same directory. Files are written atomically and carry a
+// checksum, corrupted files are detected on read and removed. Files not used for MaxAge
+// are evicted, as are the least recently used ones while the cache exceeds MaxBytes.
+type DiskBlockCache struct {
+	Dir      string
+	MaxBytes int64
+	MaxAge   time.Duration
+	Log      *logging.ZapEventLogger
+
+	mu sync.Mutex
+}
+
+// NewDiskBlockCache creates the cache directory if it does not exist yet.
+func NewDiskBlockCache(dir string, maxBytes int64, maxAge time.Duration, log *logging.ZapEventLogger) (*DiskBlockCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating block cache directory: %w", err)
+	}
+	return &DiskBlockCache{Dir: dir, MaxBytes: maxBytes, MaxAge: maxAge, Log: log}, nil
+}
+
+func (c *DiskBlockCache) path(network, hash string) (string, error) {
+	if !blockCacheKeyPattern.MatchString(network) || !blockCacheKeyPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid block cache key %q/%q", network, hash)
+	}
+	return filepath.Join(c.Dir, network, hash+".block"), nil
+}
+
+// Get returns the cached block, false if it is not cached, expired or corrupted.
+func (c *DiskBlockCache) Get(network, hash string) ([]byte, bool) {
+	path, err := c.path(network, hash)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if c.MaxAge > 0 && time.Since(info.ModTime()) > c.MaxAge {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	block, err := decodeCachedBlock(data)
+	if err != nil {
+		c.Log.Warnf("Removing corrupted block cache file %s: %v", path, err)
+		c.remove(path)
+		return nil, false
+	}
+
+	// Mark the block as recently used for eviction.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return block, true
+}
+
+// Put stores a block. The file is written to a temporary file first and renamed into
+// place, so that readers never see partial files.
+func (c *DiskBlockCache) Put(network, hash string, block []byte) error {
+	path, err := c.path(network, hash)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating block cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+hash+"-*")
+	if err != nil {
+		return fmt.Errorf("error creating block cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	sum := sha256.Sum256(block)
+	header := blockCacheHeaderPrefix + hex.EncodeToString(sum[:]) + "\n"
+	if _, err := tmp.WriteString(header); err == nil {
+		_, err = tmp.Write(block)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing block cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing block cache file: %w", err)
+	}
+	return nil
+}
+
+// decodeCachedBlock checks the checksum header of a cache file and returns the block.
+func decodeCachedBlock(data []byte) ([]byte, error) {
+	newline := bytes.IndexByte(data, '\n')
+	if newline < 0 || !bytes.HasPrefix(data, []byte(blockCacheHeaderPrefix)) {
+		return nil, errors.New("missing checksum header")
+	}
+	want := string(data[len(blockCacheHeaderPrefix):newline])
+	block := data[newline+1:]
+	sum := sha256.Sum256(block)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch: got %s, want %s", got, want)
+	}
+	return block, nil
+}
+
+// Evict removes expired files and, while the cache is larger than MaxBytes,
+// the least recently used ones. Leftover temporary files are removed as well.
+func (c *DiskBlockCache) Evict() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	now := time.Now()
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed concurrently by another run
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			// temporary files of interrupted writes
+			if now.Sub(info.ModTime()) > time.Hour {
+				c.remove(path)
+			}
+			return nil
+		}
+		if c.MaxAge > 0 && now.Sub(info.ModTime()) > c.MaxAge {
+			c.remove(path)
+			return nil
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error evicting block cache: %w", err)
+	}
+
+	if c.MaxBytes <= 0 || total <= c.MaxBytes {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		c.remove(e.path)
+		total -= e.size
+	}
+	return nil
+}
+
+func (c *DiskBlockCache) remove(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		c.Log.Warnf("Failed to remove block cache file %s: %v", path, err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+func newTestBlockCache(t *testing.T, maxBytes int64, maxAge time.Duration) *DiskBlockCache {
+	t.Helper()
+	cache, err := NewDiskBlockCache(filepath.Join(t.TempDir(), "cache"), maxBytes, maxAge, logging.Logger("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestDiskBlockCacheGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		modify func(t *testing.T, path string)
+		found  bool
+	}{
+		{name: "hit", found: true},
+		{
+			name: "corrupted block",
+			modify: func(t *testing.T, path string) {
+				data, _ := os.ReadFile(path)
+				data[len(data)-1] ^= 0xff
+				os.WriteFile(path, data, 0644)
+			},
+		},
+		{
+			name: "missing header",
+			modify: func(t *testing.T, path string) {
+				os.WriteFile(path, []byte("block"), 0644)
+			},
+		},
+		{
+			name:   "expired",
+			maxAge: time.Hour,
+			modify: func(t *testing.T, path string) {
+				old := time.Now().Add(-2 * time.Hour)
+				os.Chtimes(path, old, old)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestBlockCache(t, 0, tt.maxAge)
+			if err := cache.Put("testnet", "hash", []byte("block")); err != nil {
+				t.Fatal(err)
+			}
+			path, _ := cache.path("testnet", "hash")
+			if tt.modify != nil {
+				tt.modify(t, path)
+			}
+
+			block, found := cache.Get("testnet", "hash")
+			if found != tt.found {
+				t.Fatalf("found = %v, want %v", found, tt.found)
+			}
+			if found && string(block) != "block" {
+				t.Errorf("block = %q, want %q", block, "block")
+			}
+			if tt.name == "corrupted block" || tt.name == "missing header" {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("corrupted file was not removed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockCacheMaxBytesEnv(t *testing.T) {
+	env := &envChecker{}
+
+	t.Setenv("BLOCK_CACHE_MAX_BYTES", "")
+	if got := int64EnvChecked("BLOCK_CACHE_MAX_BYTES", defaultBlockCacheMaxBytes, env); got != 10737418240 {
+		t.Errorf("default BLOCK_CACHE_MAX_BYTES = %d, want 10737418240", got)
+	}
+	t.Setenv("BLOCK_CACHE_MAX_BYTES", "21474836480")
+	if got := int64EnvChecked("BLOCK_CACHE_MAX_BYTES", defaultBlockCacheMaxBytes, env); got != 21474836480 {
+		t.Errorf("BLOCK_CACHE_MAX_BYTES = %d, want 21474836480", got)
+	}
+	if len(env.errs) > 0 {
+		t.Errorf("int64EnvChecked() errors = %v", env.errs)
+	}
+}
+
+func TestDiskBlockCacheInvalidKey(t *testing.T) {
+	cache := newTestBlockCache(t, 0, 0)
+	for _, key := range [][2]string{{"testnet", "../hash"}, {"../testnet", "hash"}, {"testnet", ""}} {
+		if err := cache.Put(key[0], key[1], []byte("block")); err == nil {
+			t.Errorf("Put(%q, %q) succeeded, want error", key[0], key[1])
+		}
+		if _, found := cache.Get(key[0], key[1]); found {
+			t.Errorf("Get(%q, %q) found a block", key[0], key[1])
+		}
+	}
+}
+
+func TestDiskBlockCacheEvict(t *testing.T) {
+	cache := newTestBlockCache(t, 0, 0)
+	now := time.Now()
+	ages := map[string]time.Duration{"a": 4 * time.Hour, "b": 3 * time.Hour, "c": 2 * time.Hour, "d": time.Hour}
+	for hash, age := range ages {
+		if err := cache.Put("testnet", hash, []byte(hash)); err != nil {
+			t.Fatal(err)
+		}
+		path, _ := cache.path("testnet", hash)
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+	path, _ := cache.path("testnet", "a")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := info.Size()
+
+	// "b" is used, which makes "c" the least recently used block after "a" expires.
+	if _, found := cache.Get("testnet", "b"); !found {
+		t.Fatal("block b not found")
+	}
+	cache.MaxAge = 210 * time.Minute
+	cache.MaxBytes = 2 * size
+	if err := cache.Evict(); err != nil {
+		t.Fatal(err)
+	}
+
+	for hash, want := range map[string]bool{"a": false, "b": true, "c": false, "d": true} {
+		if _, found := cache.Get("testnet", hash); found != want {
+			t.Errorf("block %s found = %v, want %v", hash, found, want)
+		}
+	}
+}
+
+func TestAddMissingBlocksFromBlockCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("downloaded"))
+	}))
+	defer server.Close()
+
+	appCfg := AppConfig{
+		NetworkName:            "testnet",
+		AwsConfig:              &AwsConfig{BucketName: "bucket"},
+		BlockSourceConcurrency: 2,
+		BlockSourceMaxRetries:  1,
+	}
+	cache := newTestBlockCache(t, 0, 0)
+	if err := cache.Put("testnet", "cached", []byte("from cache")); err != nil {
+		t.Fatal(err)
+	}
+	appCtx := &AppContext{BlockSources: []BlockSource{newTestS3BlockSource(server)}, BlockCache: cache, AppConfig: appCfg, Log: logging.Logger("test")}
+
+	got := appCtx.addMissingBlocks(context.Background(), []Submission{
+		{ID: "1", BlockHash: "cached"},
+		{ID: "2", BlockHash: "new"},
+	}, appCfg)
+	if string(got[0].RawBlock) != "from cache" || string(got[1].RawBlock) != "downloaded" {
+		t.Errorf("raw blocks = %q, %q", got[0].RawBlock, got[1].RawBlock)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("S3 requests = %d, want 1", n)
+	}
+
+	// A second run finds the downloaded block in the cache.
+	got = appCtx.addMissingBlocks(context.Background(), []Submission{{ID: "3", BlockHash: "new"}}, appCfg)
+	if string(got[0].RawBlock) != "downloaded" {
+		t.Errorf("raw block = %q, want %q", got[0].RawBlock, "downloaded")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("S3 requests = %d after second run, want 1", n)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Block sources that can be listed in BLOCK_SOURCES besides s3://, file:// and http(s):// URLs.
+const (
+	// BlockSourceStore uses the raw blocks stored with the submissions.
+	BlockSourceStore = "STORE"
+	// BlockSourceS3 downloads blocks from <NETWORK_NAME>/blocks/ in AWS_S3_BUCKET.
+	BlockSourceS3 = "S3"
+)
+
+var defaultBlockSources = []string{BlockSourceStore, BlockSourceS3}
+
+// errBlockNotFound is returned by block sources that do not have the requested block.
+var errBlockNotFound = errors.New("block not found")
+
+// BlockSource fetches raw blocks by block hash.
+type BlockSource interface {
+	// Name identifies the source in logs.
+	Name() string
+	// FetchBlock returns the raw block, an error wrapping errBlockNotFound if the source does not have it.
+	FetchBlock(ctx context.Context, hash string) ([]byte, error)
+}
+
+// DirBlockSource reads blocks from <Dir>/<hash>.dat files, e.g. a mirror of the S3 blocks prefix.
+type DirBlockSource struct {
+	Dir string
+}
+
+func (s *DirBlockSource) Name() string {
+	return "file://" + s.Dir
+}
+
+func (s *DirBlockSource) FetchBlock(ctx context.Context, hash string) ([]byte, error) {
+	if !blockCacheKeyPattern.MatchString(hash) {
+		return nil, fmt.Errorf("invalid block hash %q", hash)
+	}
+	rawBlock, err := os.ReadFile(filepath.Join(s.Dir, hash+".dat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", errBlockNotFound, err)
+	}
+	return rawBlock, err
+}
+
+// HTTPBlockSource downloads blocks from <BaseURL>/<hash>.dat.
+type HTTPBlockSource struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s *HTTPBlockSource) Name() string {
+	return s.BaseURL
+}
+
+func (s *HTTPBlockSource) FetchBlock(ctx context.Context, hash string) ([]byte, error) {
+	if !blockCacheKeyPattern.MatchString(hash) {
+		return nil, fmt.Errorf("invalid block hash %q", hash)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.BaseURL, "/")+"/"+hash+".dat", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", errBlockNotFound, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	rawBlock, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return rawBlock, nil
+}
+
+// parseBlockSource parses a BLOCK_SOURCES entry. STORE is returned as nil source,
+// S3 and s3:// sources use s3Client.
+func parseBlockSource(spec string, cfg AppConfig, s3Client *s3.Client) (BlockSource, error) {
+	switch strings.ToUpper(spec) {
+	case BlockSourceStore:
+		return nil, nil
+	case BlockSourceS3:
+		bucket := ""
+		if cfg.AwsConfig != nil {
+			bucket = cfg.AwsConfig.BucketName
+		}
+		return &S3BlockSource{Client: s3Client, Bucket: bucket, Prefix: cfg.NetworkName + "/blocks/"}, nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block source %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		bucket, prefix, err := parseS3URL(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block source %q: %w", spec, err)
+		}
+		return &S3BlockSource{Client: s3Client, Bucket: bucket, Prefix: prefix}, nil
+	case "file":
+		if u.Host != "" || u.Path == "" {
+			return nil, fmt.Errorf("invalid block source %q: expected file:///absolute/path", spec)
+		}
+		return &DirBlockSource{Dir: u.Path}, nil
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid block source %q: missing host", spec)
+		}
+		return &HTTPBlockSource{BaseURL: spec}, nil
+	}
+	return nil, fmt.Errorf("invalid block source %q: expected %s, %s, s3://, file://, http:// or https://", spec, BlockSourceStore, BlockSourceS3)
+}
+
+// validateBlockSources checks BLOCK_SOURCES entries. STORE can only come first,
+// as stored blocks are available before any other source is consulted.
+func validateBlockSources(specs []string) error {
+	if len(specs) == 0 {
+		return errors.New("no block sources")
+	}
+	for i, spec := range specs {
+		if strings.ToUpper(spec) == BlockSourceStore && i != 0 {
+			return fmt.Errorf("block source %s has to be listed first", BlockSourceStore)
+		}
+		if _, err := parseBlockSource(spec, AppConfig{}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBlockSources returns the block sources configured in cfg.BlockSources, excluding STORE.
+func newBlockSources(cfg AppConfig, s3Client *s3.Client) ([]BlockSource, error) {
+	specs := cfg.BlockSources
+	if len(specs) == 0 {
+		specs = defaultBlockSources
+	}
+	var sources []BlockSource
+	for _, spec := range specs {
+		source, err := parseBlockSource(spec, cfg, s3Client)
+		if err != nil {
+			return nil, err
+		}
+		if source != nil {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// usesStoredBlocks reports whether raw blocks stored with the submissions are used.
+func usesStoredBlocks(cfg AppConfig) bool {
+	return len(cfg.BlockSources) == 0 || strings.ToUpper(cfg.BlockSources[0]) == BlockSourceStore
+}
+
+// addMissingBlocks fetches the raw blocks of submissions that have none from the block sources.
+// Every source is tried in order until one has the block. Each distinct block hash is fetched
+// once, with up to BlockSourceConcurrency fetches in parallel. Blocks that can not be
+// fetched are left empty. If a block cache is configured, it is consulted first and fetched
+// blocks are added to it.
+func (appCtx *AppContext) addMissingBlocks(ctx context.Context, submissions []Submission, appCfg AppConfig) []Submission {
+	if !usesStoredBlocks(appCfg) {
+		for i := range submissions {
+			submissions[i].RawBlock = nil
+		}
+	}
+
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, sub := range submissions {
+		if len(sub.RawBlock) == 0 && !seen[sub.BlockHash] {
+			seen[sub.BlockHash] = true
+			hashes = append(hashes, sub.BlockHash)
+		}
+	}
+	if len(hashes) == 0 {
+		return submissions
+	}
+
+	blockCache := make(map[string][]byte, len(hashes)) // Cache for holding block data
+	if appCtx.BlockCache != nil {
+		var missing []string
+		for _, hash := range hashes {
+			if rawBlock, found := appCtx.BlockCache.Get(appCfg.NetworkName, hash); found {
+				blockCache[hash] = rawBlock
+			} else {
+				missing = append(missing, hash)
+			}
+		}
+		appCtx.Log.Infof("Found %d of %d blocks in the block cache", len(blockCache), len(hashes))
+		hashes = missing
+	}
+
+	if len(hashes) > 0 {
+		fetched := appCtx.fetchBlocks(ctx, hashes, appCfg)
+		for hash, rawBlock := range fetched {
+			blockCache[hash] = rawBlock
+			if appCtx.BlockCache != nil && !isEmptyRawBlock(rawBlock) {
+				if err := appCtx.BlockCache.Put(appCfg.NetworkName, hash, rawBlock); err != nil {
+					appCtx.Log.Warnf("Failed to cache block %s: %v", hash, err)
+				}
+			}
+		}
+	}
+	if appCtx.BlockCache != nil {
+		if err := appCtx.BlockCache.Evict(); err != nil {
+			appCtx.Log.Warnf("Failed to evict block cache: %v", err)
+		}
+	}
+
+	for i, sub := range submissions {
+		if len(sub.RawBlock) == 0 {
+			if rawBlock, found := blockCache[sub.BlockHash]; found {
+				submissions[i].RawBlock = rawBlock
+			}
+		}
+	}
+
+	return submissions
+}
+
+// fetchBlocks fetches the blocks with the given hashes concurrently and returns them by hash.
+// Blocks that could not be fetched are missing from the result.
+func (appCtx *AppContext) fetchBlocks(ctx context.Context, hashes []string, appCfg AppConfig) map[string][]byte {
+	if len(appCtx.BlockSources) == 0 {
+		appCtx.Log.Warnf("No block sources configured, %d blocks are missing", len(hashes))
+		return nil
+	}
+	concurrency := appCfg.BlockSourceConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	names := make([]string, len(appCtx.BlockSources))
+	for i, source := range appCtx.BlockSources {
+		names[i] = source.Name()
+	}
+	appCtx.Log.Infof("Fetching %d blocks from %s with concurrency %d", len(hashes), strings.Join(names, ", "), concurrency)
+
+	var mu sync.Mutex
+	blockCache := make(map[string][]byte, len(hashes))
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hash := range jobs {
+				rawBlock, found := appCtx.fetchBlockFromSources(ctx, hash, appCfg)
+				if !found {
+					// Continue to next block instead of stopping
+					continue
+				}
+
+				// if block is empty, assign "" such that delegation-verify
+				// does not stop processing, also cache the block data
+				if len(rawBlock) == 0 {
+					rawBlock = []byte(`""`)
+				}
+				mu.Lock()
+				blockCache[hash] = rawBlock
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, hash := range hashes {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- hash
+	}
+	close(jobs)
+	wg.Wait()
+
+	return blockCache
+}
+
+// fetchBlockFromSources tries the block sources in order and returns the block of the first
+// source that has it.
+func (appCtx *AppContext) fetchBlockFromSources(ctx context.Context, hash string, appCfg AppConfig) ([]byte, bool) {
+	for _, source := range appCtx.BlockSources {
+		rawBlock, err := fetchBlock(ctx, source, hash, appCfg)
+		if err == nil {
+			return rawBlock, true
+		}
+		if ctx.Err() != nil {
+			return nil, false
+		}
+		if errors.Is(err, errBlockNotFound) {
+			appCtx.Log.Debugf("Block %s not found in %s", hash, source.Name())
+		} else {
+			appCtx.Log.Errorf("Failed to get block %s from %s: %v", hash, source.Name(), err)
+		}
+	}
+	appCtx.Log.Errorf("Block %s not found in any block source", hash)
+	return nil, false
+}
+
+// fetchBlock fetches a single block from source, retrying failed requests with exponential backoff.
+// Every attempt is bounded by BlockSourceTimeout. Missing blocks are not retried.
+func fetchBlock(ctx context.Context, source BlockSource, hash string, appCfg AppConfig) ([]byte, error) {
+	retries := appCfg.BlockSourceMaxRetries
+	if retries < 1 {
+		retries = 1
+	}
+
+	var rawBlock []byte
+	var notFound error
+	err := ExponentialBackoff(ctx, func() error {
+		reqCtx := ctx
+		if timeout := appCfg.BlockSourceTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		var err error
+		rawBlock, err = source.FetchBlock(reqCtx, hash)
+		if errors.Is(err, errBlockNotFound) {
+			notFound = err
+			return nil
+		}
+		return err
+	}, retries, initialBackoff)
+	if err != nil {
+		return nil, err
+	}
+	if notFound != nil {
+		return nil, notFound
+	}
+	return rawBlock, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+func TestLoadEnvFetchSettings(t *testing.T) {
+	t.Setenv("SUBMISSION_STORAGE", "MEMORY")
+	t.Setenv("NETWORK_NAME", "testnet")
+	t.Setenv("AWS_S3_BUCKET", "test-bucket")
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("S3_DOWNLOAD_CONCURRENCY", "4")
+	t.Setenv("BLOCK_SOURCE_TIMEOUT", "5s")
+	t.Setenv("BLOCK_SOURCE_MAX_RETRIES", "2")
+	t.Setenv("S3_STORE_CONCURRENCY", "3")
+	t.Setenv("ARCHIVE_S3_URL", "s3://archive/prefix/")
+	t.Setenv("ARCHIVE_CONCURRENCY", "6")
+
+	cfg, err := LoadEnv()
+	if err != nil {
+		t.Fatalf("LoadEnv() error = %v", err)
+	}
+	if cfg.BlockSourceConcurrency != 4 || cfg.BlockSourceTimeout != 5*time.Second || cfg.BlockSourceMaxRetries != 2 {
+		t.Errorf("block source settings = %d, %v, %d, want 4, 5s, 2",
+			cfg.BlockSourceConcurrency, cfg.BlockSourceTimeout, cfg.BlockSourceMaxRetries)
+	}
+	if cfg.AwsConfig.StoreConcurrency != 3 {
+		t.Errorf("AwsConfig.StoreConcurrency = %d, want 3", cfg.AwsConfig.StoreConcurrency)
+	}
+	if cfg.ArchiveConfig == nil || cfg.ArchiveConfig.Concurrency != 6 {
+		t.Errorf("ArchiveConfig = %+v, want concurrency 6", cfg.ArchiveConfig)
+	}
+}
+
+func TestParseBlockSource(t *testing.T) {
+	cfg := AppConfig{NetworkName: "testnet", AwsConfig: &AwsConfig{BucketName: "bucket"}}
+	tests := []struct {
+		spec    string
+		want    BlockSource
+		wantErr bool
+	}{
+		{spec: "STORE", want: nil},
+		{spec: "s3", want: &S3BlockSource{Bucket: "bucket", Prefix: "testnet/blocks/"}},
+		{spec: "s3://mirror/mainnet/blocks", want: &S3BlockSource{Bucket: "mirror", Prefix: "mainnet/blocks/"}},
+		{spec: "s3://mirror", want: &S3BlockSource{Bucket: "mirror"}},
+		{spec: "file:///mnt/blocks", want: &DirBlockSource{Dir: "/mnt/blocks"}},
+		{spec: "https://example.com/blocks", want: &HTTPBlockSource{BaseURL: "https://example.com/blocks"}},
+		{spec: "s3://", wantErr: true},
+		{spec: "file://relative/blocks", wantErr: true},
+		{spec: "http://", wantErr: true},
+		{spec: "/mnt/blocks", wantErr: true},
+		{spec: "ftp://example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseBlockSource(tt.spec, cfg, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBlockSources(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		wantErr bool
+	}{
+		{specs: []string{"STORE", "S3"}},
+		{specs: []string{"file:///mnt/blocks", "https://example.com"}},
+		{specs: nil, wantErr: true},
+		{specs: []string{"S3", "STORE"}, wantErr: true},
+		{specs: []string{"STORE", "bogus"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateBlockSources(tt.specs); (err != nil) != tt.wantErr {
+			t.Errorf("validateBlockSources(%v) = %v, wantErr %v", tt.specs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAddMissingBlocksFromSourceChain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.dat"), []byte("block local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var httpRequests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpRequests = append(httpRequests, r.URL.Path)
+		switch r.URL.Path {
+		case "/blocks/remote.dat":
+			w.Write([]byte("block remote"))
+		case "/blocks/broken.dat":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		blockSource []string
+		want        map[string]string
+	}{
+		{
+			name:        "stored blocks are used",
+			blockSource: []string{"STORE", "file://" + dir, server.URL + "/blocks"},
+			want:        map[string]string{"1": "block local", "2": "block remote", "3": "", "4": "stored", "5": ""},
+		},
+		{
+			name:        "stored blocks are replaced",
+			blockSource: []string{"file://" + dir, server.URL + "/blocks"},
+			want:        map[string]string{"1": "block local", "2": "block remote", "3": "", "4": "", "5": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpRequests = nil
+			appCfg := AppConfig{
+				NetworkName:            "testnet",
+				BlockSources:           tt.blockSource,
+				AwsConfig:              &AwsConfig{},
+				BlockSourceConcurrency: 1,
+				BlockSourceMaxRetries:  2,
+			}
+			sources, err := newBlockSources(appCfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			appCtx := &AppContext{BlockSources: sources, AppConfig: appCfg, Log: logging.Logger("test")}
+
+			got := appCtx.addMissingBlocks(context.Background(), []Submission{
+				{ID: "1", BlockHash: "local"},
+				{ID: "2", BlockHash: "remote"},
+				{ID: "3", BlockHash: "missing"},
+				{ID: "4", BlockHash: "stored", RawBlock: RawBlock("stored")},
+				{ID: "5", BlockHash: "broken"},
+			}, appCfg)
+			for _, sub := range got {
+				if string(sub.RawBlock) != tt.want[sub.ID] {
+					t.Errorf("submission %s raw block = %q, want %q", sub.ID, sub.RawBlock, tt.want[sub.ID])
+				}
+			}
+			for _, path := range httpRequests {
+				if path == "/blocks/local.dat" {
+					t.Errorf("block found in the local directory was requested over HTTP")
+				}
+			}
+			broken := 0
+			for _, path := range httpRequests {
+				if path == "/blocks/broken.dat" {
+					broken++
+				}
+			}
+			if broken != 2 {
+				t.Errorf("broken block requested %d times, want 2", broken)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,8 +14,30 @@ import (
 	"github.com/aws/aws-sigv4-auth-cassandra-gocql-driver-plugin/sigv4"
 	"github.com/gocql/gocql"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	logging "github.com/ipfs/go-log/v2"
 )
 
+func init() {
+	RegisterSubmissionStore("CASSANDRA", NewCassandraStore)
+}
+
+// CassandraStore is a SubmissionStore backed by Cassandra/AWS Keyspaces.
+type CassandraStore struct {
+	Session *gocql.Session
+	Log     *logging.ZapEventLogger
+	// StoreVerifierVersion enables writing the verifier_version column.
+	StoreVerifierVersion bool
+}
+
+// NewCassandraStore connects to Cassandra using config.CassandraConfig.
+func NewCassandraStore(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+	session, err := InitializeCassandraSession(config.CassandraConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &CassandraStore{Session: session, Log: log, StoreVerifierVersion: config.StoreVerifierVersion}, nil
+}
+
 // InitializeCassandraSession creates a new gocql session for Amazon Keyspaces using the provided configuration.
 func InitializeCassandraSession(config *CassandraConfig) (*gocql.Session, error) {
 	var cluster *gocql.ClusterConfig
@@ -107,16 +131,53 @@ func sigv4Authentication(config *CassandraConfig) (sigv4.AwsAuthenticator, error
 	return auth, nil
 }
 
-func (ctx *AppContext) selectRangeCassandra(startTime, endTime time.Time) ([]Submission, error) {
-
-	query := `SELECT submitted_at_date, shard, submitted_at, submitter, created_at, block_hash, 
+const cassandraSelectColumns = `SELECT submitted_at_date, shard, submitted_at, submitter, created_at, block_hash, 
 			  raw_block, remote_addr, peer_id, snark_work, graphql_control_port, built_with_commit_sha, 
 			  state_hash, parent, height, slot, validation_error, verified
-              FROM submissions
+              FROM submissions`
+
+func (store *CassandraStore) SelectRange(ctx context.Context, startTime, endTime time.Time) ([]Submission, error) {
+
+	query := cassandraSelectColumns + `
               WHERE ` + calculateDateRange(startTime, endTime) +
 		` AND ` + shardsToCql(calculateShardsInRange(startTime, endTime)) +
 		` AND submitted_at >= ? AND submitted_at < ?`
-	iter := ctx.CassandraSession.Query(query, startTime, endTime).Iter()
+	return store.selectSubmissions(ctx, query, startTime, endTime)
+}
+
+// SelectByIDs returns the submissions with the given keys.
+// Cassandra submissions have no ID column, so keys are in the
+// submitted_at_date/shard/submitted_at/submitter format returned by submissionKey.
+func (store *CassandraStore) SelectByIDs(ctx context.Context, ids []string) ([]Submission, error) {
+	query := cassandraSelectColumns + `
+              WHERE submitted_at_date = ? AND shard = ? AND submitted_at = ? AND submitter = ?`
+
+	var submissions []Submission
+	for _, id := range ids {
+		parts := strings.SplitN(id, "/", 4)
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid submission key %s, expected submitted_at_date/shard/submitted_at/submitter", id)
+		}
+		shard, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid shard in submission key %s: %w", id, err)
+		}
+		submittedAt, err := time.Parse(time.RFC3339Nano, parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid submitted_at in submission key %s: %w", id, err)
+		}
+
+		found, err := store.selectSubmissions(ctx, query, parts[0], shard, submittedAt, parts[3])
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, found...)
+	}
+	return submissions, nil
+}
+
+func (store *CassandraStore) selectSubmissions(ctx context.Context, query string, values ...interface{}) ([]Submission, error) {
+	iter := store.Session.Query(query, values...).WithContext(ctx).Iter()
 
 	var submissions []Submission
 	for {
@@ -132,15 +193,15 @@ func (ctx *AppContext) selectRangeCassandra(startTime, endTime time.Time) ([]Sub
 		submissions = append(submissions, submission)
 	}
 	if err := iter.Close(); err != nil {
-		ctx.Log.Errorf("Error closing iterator: %s", err)
+		store.Log.Errorf("Error closing iterator: %s", err)
 		return nil, err
 	}
 
 	return submissions, nil
 }
 
-func (ctx *AppContext) tryUpdateSubmissionsCassandra(submissions []Submission) error {
-	ctx.Log.Infof("Updating %d submissions", len(submissions))
+func (store *CassandraStore) tryUpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	store.Log.Infof("Updating %d submissions", len(submissions))
 	for _, sub := range submissions {
 		// Update the submission
 		// Note: raw_block and snark_work are reseted to nil since we don't want to keep them in the database
@@ -148,29 +209,47 @@ func (ctx *AppContext) tryUpdateSubmissionsCassandra(submissions []Submission) e
                   SET state_hash = ?, parent = ?, height = ?, slot = ?, validation_error = ?, verified = ?, 
 				  raw_block = ?, snark_work = ?
                   WHERE submitted_at_date = ? AND shard = ? AND submitted_at = ? AND submitter = ?`
-		if err := ctx.CassandraSession.Query(query,
-			sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
+		values := []interface{}{sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
 			nil, nil,
-			sub.SubmittedAtDate, sub.Shard, sub.SubmittedAt, sub.Submitter).Exec(); err != nil {
-			ctx.Log.Errorf("Failed to update submission: %v", err)
+			sub.SubmittedAtDate, sub.Shard, sub.SubmittedAt, sub.Submitter}
+		if store.StoreVerifierVersion {
+			query = `UPDATE submissions
+                  SET state_hash = ?, parent = ?, height = ?, slot = ?, validation_error = ?, verified = ?, 
+				  raw_block = ?, snark_work = ?, verifier_version = ?
+                  WHERE submitted_at_date = ? AND shard = ? AND submitted_at = ? AND submitter = ?`
+			values = []interface{}{sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
+				nil, nil, sub.VerifierVersion,
+				sub.SubmittedAtDate, sub.Shard, sub.SubmittedAt, sub.Submitter}
+		}
+		if err := store.Session.Query(query, values...).WithContext(ctx).Exec(); err != nil {
+			store.Log.Errorf("Failed to update submission: %v", err)
 			return err
 		}
 	}
-	ctx.Log.Infof("Submissions updated")
+	store.Log.Infof("Submissions updated")
 
 	return nil
 }
 
-func (ctx *AppContext) updateSubmissionsCassandra(submissions []Submission) error {
-	return ExponentialBackoff(func() error {
-		if err := ctx.tryUpdateSubmissionsCassandra(submissions); err != nil {
-			ctx.Log.Errorf("Error updating submissions (trying again): %v", err)
+func (store *CassandraStore) UpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	return ExponentialBackoff(ctx, func() error {
+		if err := store.tryUpdateSubmissions(ctx, submissions); err != nil {
+			store.Log.Errorf("Error updating submissions (trying again): %v", err)
 			return err
 		}
 		return nil
 	}, maxRetries, initialBackoff)
 }
 
+func (store *CassandraStore) HealthCheck(ctx context.Context) error {
+	return store.Session.Query("SELECT now() FROM system.local").WithContext(ctx).Exec()
+}
+
+func (store *CassandraStore) Close() error {
+	store.Session.Close()
+	return nil
+}
+
 func calculateDateRange(startTime, endTime time.Time) string {
 	var dateRange []string
 	current := startTime
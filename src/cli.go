@@ -0,0 +1,711 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// Exit codes of the program.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const dateLayout = "2006-01-02 15:04:05.0-0700"
+
+// usageError is returned by commands when they are invoked with invalid arguments.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string, log *logging.ZapEventLogger) error
+}
+
+// commands lists the subcommands of the program. The first one is run
+// when the arguments do not start with a command name.
+var commands = []command{
+	{"verify", "verify submissions in a time range and update them", runVerifyCommand},
+	{"reverify", "verify individual submissions selected by ID and update them", runReverifyCommand},
+	{"shards", "print the Cassandra partitions a time range touches", runShardsCommand},
+	{"config", "check the configuration (config check)", runConfigCommand},
+	{"export", "export stored verification results of a time range", runExportCommand},
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: submission-updater <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Run 'submission-updater <command> --help' for details on a command.")
+	fmt.Fprintln(os.Stderr, "For backwards compatibility, arguments without a command are passed to verify.")
+}
+
+// runCLI runs the command selected by args and returns the exit code of the program.
+// Cancelling ctx, e.g. on SIGTERM, stops the command: running verifier processes are killed
+// and no further batches are started or written back.
+func runCLI(ctx context.Context, args []string, log *logging.ZapEventLogger) int {
+	if len(args) == 0 {
+		printUsage()
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage()
+		return exitOK
+	}
+
+	cmd := commands[0]
+	found := false
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd, found = c, true
+			break
+		}
+	}
+	if found {
+		args = args[1:]
+	}
+
+	flagEnvs = make(map[string]string)
+	err := cmd.run(ctx, args, log)
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case ctx.Err() != nil:
+		log.Errorf("Command %s interrupted: %v", cmd.name, err)
+		return exitFailure
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		fmt.Fprintf(os.Stderr, "Run 'submission-updater %s --help' for usage.\n", cmd.name)
+		return exitUsage
+	default:
+		log.Errorf("Command %s failed: %v", cmd.name, err)
+		return exitFailure
+	}
+}
+
+// newFlagSet creates a flag set for a command. Parsing errors are returned
+// instead of exiting, so that they can be turned into exitUsage.
+func newFlagSet(name, arguments, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: submission-updater %s %s\n\n%s\n\nFlags:\n", name, arguments, description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+	return nil
+}
+
+// envFlag is a command line flag that overrides an environment variable read by LoadEnv.
+type envFlag struct {
+	name   string
+	env    string
+	isBool bool
+}
+
+// flagEnvs maps the environment variables set by flags of the running command to the flag names,
+// so that invalid values can be reported as usage errors.
+var flagEnvs = make(map[string]string)
+
+func (f *envFlag) String() string {
+	return ""
+}
+
+func (f *envFlag) Set(value string) error {
+	if f.isBool {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		value = "0"
+		if b {
+			value = "1"
+		}
+	}
+	flagEnvs[f.env] = f.name
+	return os.Setenv(f.env, value)
+}
+
+func (f *envFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+var envOverrideFlags = []struct {
+	name   string
+	env    string
+	isBool bool
+	usage  string
+}{
+	{"storage", "SUBMISSION_STORAGE", false, "submission storage backend"},
+	{"network", "NETWORK_NAME", false, "network name"},
+	{"bucket", "AWS_S3_BUCKET", false, "S3 bucket blocks are stored in"},
+	{"s3-endpoint", "S3_ENDPOINT_URL", false, "URL of an S3-compatible endpoint used instead of AWS S3"},
+	{"s3-path-style", "S3_USE_PATH_STYLE", true, "use path-style S3 addressing"},
+	{"block-source-concurrency", "BLOCK_SOURCE_CONCURRENCY", false, "number of blocks fetched from the block sources in parallel"},
+	{"block-sources", "BLOCK_SOURCES", false, "comma separated, ordered block sources: STORE, S3, s3://bucket/prefix, file:///dir, http(s)://base-url"},
+	{"archive-url", "ARCHIVE_S3_URL", false, "s3://bucket/prefix submissions are archived to before their payloads are dropped"},
+	{"block-cache-dir", "BLOCK_CACHE_DIR", false, "directory of the on-disk block cache consulted before S3"},
+	{"verifier", "DELEGATION_VERIFIER", false, "verifier implementation: SUBPROCESS or FAKE"},
+	{"verifier-bin", "DELEGATION_VERIFY_BIN_PATH", false, "path to the delegation-verify binary"},
+	{"verifier-env", "DELEGATION_VERIFY_ENV", false, "space separated KEY=VALUE entries added to the delegation-verify environment"},
+	{"verifier-workdir", "DELEGATION_VERIFY_WORKDIR", false, "working directory of delegation-verify"},
+	{"output-contract", "DELEGATION_VERIFY_OUTPUT_CONTRACT", false, "contract verifier results are checked against: v1 or NONE"},
+	{"output-policy", "DELEGATION_VERIFY_OUTPUT_POLICY", false, "what to do with verifier results not conforming to the contract: ERROR or QUARANTINE"},
+	{"quarantine-file", "DELEGATION_VERIFY_QUARANTINE_FILE", false, "JSONL file quarantined verifier results are appended to"},
+	{"genesis-ledger-file", "GENESIS_LEDGER_FILE", false, "genesis ledger file passed to delegation-verify"},
+	{"no-checks", "NO_CHECKS", true, "run delegation-verify with --no-checks"},
+	{"batch-size", "VERIFY_BATCH_SIZE", false, "maximum number of submissions per verifier run"},
+	{"batch-max-bytes", "VERIFY_BATCH_MAX_BYTES", false, "maximum raw block bytes per verifier run"},
+	{"concurrency", "VERIFY_CONCURRENCY", false, "number of verifier processes run in parallel"},
+	{"bisect-max-runs", "VERIFY_BISECT_MAX_RUNS", false, "maximum verifier runs spent isolating failing submissions of a batch, 0 disables bisection"},
+	{"verify-timeout", "DELEGATION_VERIFY_TIMEOUT", false, "maximum run time of a verifier process, 0 disables the timeout"},
+	{"summary-file", "RUN_SUMMARY_FILE", false, "file the JSON run summary is written to"},
+	{"unreturned-policy", "UNRETURNED_POLICY", false, "what to do with submissions the verifier returned no result for: IGNORE or MARK"},
+}
+
+// addEnvFlags registers flags overriding the environment variables read by LoadEnv.
+func addEnvFlags(flags *flag.FlagSet) {
+	for _, f := range envOverrideFlags {
+		flags.Var(&envFlag{name: f.name, env: f.env, isBool: f.isBool}, f.name, fmt.Sprintf("%s (overrides %s)", f.usage, f.env))
+	}
+}
+
+// processingOptions are the flags shared by commands that verify and update submissions.
+type processingOptions struct {
+	dryRun       bool
+	output       string
+	outputFormat string
+	verifierArgs stringsFlag
+}
+
+func (opts *processingOptions) addFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "verify submissions and report the would-be updates without writing them")
+	flags.StringVar(&opts.output, "output", "", "export verified submissions to this file")
+	flags.StringVar(&opts.outputFormat, "output-format", "", "format of the --output file: jsonl or csv (default: derived from the file extension)")
+	flags.Var(&opts.verifierArgs, "verifier-arg", "additional delegation-verify argument, appended after DELEGATION_VERIFY_EXTRA_ARGS, can be repeated")
+}
+
+func (opts *processingOptions) apply(cfg *AppConfig) {
+	cfg.DryRun = opts.dryRun
+	cfg.Output = opts.output
+	cfg.OutputFormat = opts.outputFormat
+	cfg.VerifierExtraArgs = append(cfg.VerifierExtraArgs, opts.verifierArgs...)
+}
+
+// loadConfig loads the configuration from the environment. If a value given with a flag
+// is invalid, the errors are returned as a usage error.
+func loadConfig() (AppConfig, error) {
+	appCfg, err := LoadEnv()
+	if err == nil {
+		return appCfg, nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var configErr *ConfigError
+			if errors.As(e, &configErr) && flagEnvs[configErr.Variable] != "" {
+				return AppConfig{}, usageErrorf("invalid value for --%s: %v", flagEnvs[configErr.Variable], err)
+			}
+		}
+	}
+	return AppConfig{}, fmt.Errorf("error loading configuration: %w", err)
+}
+
+// startApp loads the configuration from the environment and connects to the submission store and S3.
+func startApp(ctx context.Context, log *logging.ZapEventLogger, opts processingOptions) (*AppContext, error) {
+	appCfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts.apply(&appCfg)
+
+	log.Info("Submission Updater started...")
+	log.Info("Using SUBMISSION_STORAGE: ", appCfg.SubmissionStorage)
+	if appCfg.DryRun {
+		log.Info("Note! Running in dry-run mode. Submissions will be verified but not updated.")
+	}
+	if appCfg.Verifier == VerifierSubprocess {
+		if appCfg.DelegationVerifyBinPath == "" {
+			return nil, fmt.Errorf("missing DELEGATION_VERIFY_BIN_PATH environment variable")
+		}
+		log.Infof("Using DELEGATION_VERIFY_BIN_PATH: %v", appCfg.DelegationVerifyBinPath)
+	} else {
+		log.Infof("Using DELEGATION_VERIFIER: %v", appCfg.Verifier)
+	}
+
+	appCtx, err := NewAppContext(ctx, appCfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("error creating context: %w", err)
+	}
+	log.Infof("S3 session initialized")
+	return appCtx, nil
+}
+
+// startStore loads the configuration and opens the submission storage only,
+// for commands that do not verify submissions.
+func startStore(ctx context.Context, log *logging.ZapEventLogger) (AppConfig, SubmissionStore, error) {
+	appCfg, err := loadConfig()
+	if err != nil {
+		return AppConfig{}, nil, err
+	}
+	log.Info("Using SUBMISSION_STORAGE: ", appCfg.SubmissionStorage)
+	store, err := NewSubmissionStore(ctx, appCfg, log)
+	if err != nil {
+		return AppConfig{}, nil, fmt.Errorf("error opening submission storage: %w", err)
+	}
+	return appCfg, store, nil
+}
+
+// rangeHelp describes the accepted time range arguments in command help.
+const rangeHelp = "The range is given as <start> <end>, as a single <start>..<end> argument or with --since, --slots or --epochs.\n" +
+	"Times can be given as \"" + dateLayout + "\", RFC3339, Unix epoch seconds or relative\n" +
+	"to the current time (e.g. now, now-10m, -1h). Use -- before arguments starting with -, e.g. -- -1h..now.\n" +
+	"Slots and epochs are converted to time using the genesis timestamp in GENESIS_LEDGER_FILE."
+
+// rangeOptions are the flags and arguments selecting a time range.
+type rangeOptions struct {
+	since  string
+	slots  string
+	epochs string
+}
+
+func (opts *rangeOptions) addFlags(flags *flag.FlagSet) {
+	flags.StringVar(&opts.since, "since", "", "select the range from now minus this duration (e.g. 30m, 2d) until now")
+	flags.StringVar(&opts.slots, "slots", "", "select the range covering the slots <first>..<last> (inclusive) or a single slot")
+	flags.StringVar(&opts.epochs, "epochs", "", "select the range covering the epochs <first>..<last> (inclusive) or a single epoch")
+}
+
+// parseIntRange parses "<first>..<last>" or a single number, which is both first and last.
+func parseIntRange(value string) (first, last int, err error) {
+	parts := strings.Split(value, "..")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("invalid range %q, expected <first>..<last>", value)
+	}
+	if first, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q: %w", value, err)
+	}
+	last = first
+	if len(parts) == 2 {
+		if last, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid range %q: %w", value, err)
+		}
+	}
+	return first, last, nil
+}
+
+// parseSlotRange converts the --slots or --epochs option to a time range,
+// using the genesis constants of GENESIS_LEDGER_FILE.
+func (opts *rangeOptions) parseSlotRange() (startTime, endTime time.Time, err error) {
+	name, value := "--slots", opts.slots
+	if opts.epochs != "" {
+		name, value = "--epochs", opts.epochs
+	}
+	first, last, err := parseIntRange(value)
+	if err != nil {
+		return startTime, endTime, usageErrorf("error parsing %s: %v", name, err)
+	}
+
+	genesisLedgerFile := os.Getenv("GENESIS_LEDGER_FILE")
+	if genesisLedgerFile == "" {
+		return startTime, endTime, usageErrorf("%s requires GENESIS_LEDGER_FILE to be set", name)
+	}
+	genesis, err := LoadGenesisConstants(genesisLedgerFile)
+	if err != nil {
+		return startTime, endTime, err
+	}
+
+	if opts.epochs != "" {
+		startTime, endTime, err = genesis.EpochRange(first, last)
+	} else {
+		startTime, endTime, err = genesis.SlotRange(first, last)
+	}
+	if err != nil {
+		return startTime, endTime, usageErrorf("error parsing %s: %v", name, err)
+	}
+	return startTime, endTime, nil
+}
+
+func parseTime(name, value string, now time.Time) (time.Time, error) {
+	t, err := parseTimeExpr(value, now)
+	if err != nil {
+		return time.Time{}, usageErrorf("error parsing %s: %v", name, err)
+	}
+	return t, nil
+}
+
+// parse returns the range selected by the flags and positional arguments, in UTC.
+func (opts *rangeOptions) parse(positional []string, now time.Time) (startTime, endTime time.Time, err error) {
+	selectors := 0
+	for _, option := range []string{opts.since, opts.slots, opts.epochs} {
+		if option != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return startTime, endTime, usageErrorf("only one of --since, --slots and --epochs can be given")
+	}
+
+	switch {
+	case opts.slots != "" || opts.epochs != "":
+		if len(positional) > 0 {
+			return startTime, endTime, usageErrorf("unexpected arguments %v with --slots or --epochs", positional)
+		}
+		return opts.parseSlotRange()
+	case opts.since != "":
+		if len(positional) > 0 {
+			return startTime, endTime, usageErrorf("unexpected arguments %v with --since", positional)
+		}
+		since, err := parseDurationExpr(opts.since)
+		if err != nil || since <= 0 {
+			return startTime, endTime, usageErrorf("invalid --since %q, expected a positive duration", opts.since)
+		}
+		return now.Add(-since).UTC(), now.UTC(), nil
+	case len(positional) == 1:
+		if startTime, endTime, err = parseRangeExpr(positional[0], now); err != nil {
+			return startTime, endTime, usageErrorf("error parsing range: %v", err)
+		}
+	case len(positional) == 2:
+		if startTime, err = parseTime("start date", positional[0], now); err != nil {
+			return
+		}
+		if endTime, err = parseTime("end date", positional[1], now); err != nil {
+			return
+		}
+	default:
+		return startTime, endTime, usageErrorf("expected <start> <end>, <start>..<end>, --since, --slots or --epochs, got %d arguments", len(positional))
+	}
+	if !endTime.After(startTime) {
+		err = usageErrorf("end %s is not after start %s", endTime.Format(time.RFC3339), startTime.Format(time.RFC3339))
+	}
+	return
+}
+
+func runVerifyCommand(ctx context.Context, args []string, log *logging.ZapEventLogger) error {
+	flags := newFlagSet("verify", "[flags] <start> <end>\n       submission-updater verify --follow [flags] [<start>]",
+		"Selects submissions with submitted_at in [start, end), verifies them with delegation-verify\n"+
+			"and writes the results back to the submission storage.\n"+
+			"With --follow, consecutive windows are processed continuously starting at the stored watermark.\n"+rangeHelp)
+	var opts processingOptions
+	opts.addFlags(flags)
+	var timeRange rangeOptions
+	timeRange.addFlags(flags)
+	follow := flags.Bool("follow", false, "continuously process windows following the stored watermark")
+	addEnvFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	positional := flags.Args()
+
+	var startTime, endTime time.Time
+	var err error
+	now := time.Now()
+	if *follow {
+		if opts.dryRun || opts.output != "" || timeRange != (rangeOptions{}) {
+			return usageErrorf("--dry-run, --output, --since, --slots and --epochs can not be combined with --follow")
+		}
+		if len(positional) > 1 {
+			return usageErrorf("expected at most <start> with --follow, got %d arguments", len(positional))
+		}
+		if len(positional) == 1 {
+			if startTime, err = parseTime("start date", positional[0], now); err != nil {
+				return err
+			}
+		}
+	} else if startTime, endTime, err = timeRange.parse(positional, now); err != nil {
+		return err
+	}
+
+	appCtx, err := startApp(ctx, log, opts)
+	if err != nil {
+		return err
+	}
+	defer appCtx.Close()
+
+	if *follow {
+		if appCtx.AppConfig.FollowConfig.StateFile == "" {
+			return fmt.Errorf("missing FOLLOW_STATE_FILE environment variable, required with --follow")
+		}
+		watermark := &FileWatermark{Path: appCtx.AppConfig.FollowConfig.StateFile}
+		err := appCtx.follow(ctx, watermark, startTime)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("error following submissions: %w", err)
+		}
+		return nil
+	}
+
+	if err := appCtx.processRange(ctx, startTime, endTime); err != nil {
+		return fmt.Errorf("error processing range: %w", err)
+	}
+	return nil
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func runReverifyCommand(ctx context.Context, args []string, log *logging.ZapEventLogger) error {
+	flags := newFlagSet("reverify", "[flags] --id <id> [--id <id> ...]",
+		"Verifies the submissions with the given IDs and writes the results back to the submission storage.\n"+
+			"For CASSANDRA, IDs are submission keys in the submitted_at_date/shard/submitted_at/submitter format.")
+	var opts processingOptions
+	opts.addFlags(flags)
+	var ids stringsFlag
+	flags.Var(&ids, "id", "ID of a submission to verify, can be repeated")
+	addEnvFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("unexpected arguments %v", flags.Args())
+	}
+	if len(ids) == 0 {
+		return usageErrorf("at least one --id is required")
+	}
+
+	appCtx, err := startApp(ctx, log, opts)
+	if err != nil {
+		return err
+	}
+	defer appCtx.Close()
+
+	selector, ok := appCtx.Store.(SubmissionIDSelector)
+	if !ok {
+		return fmt.Errorf("submission storage %s does not support selecting submissions by ID", appCtx.AppConfig.SubmissionStorage)
+	}
+	submissions, err := selector.SelectByIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("error selecting submissions: %w", err)
+	}
+	if len(submissions) != len(ids) {
+		log.Warnf("Found %d of %d requested submissions", len(submissions), len(ids))
+	}
+
+	if err := appCtx.processSubmissions(ctx, submissions, time.Time{}, time.Time{}, nil); err != nil {
+		return fmt.Errorf("error processing submissions: %w", err)
+	}
+	return nil
+}
+
+func runShardsCommand(ctx context.Context, args []string, log *logging.ZapEventLogger) error {
+	flags := newFlagSet("shards", "[flags] <start> <end>",
+		"Prints the submitted_at_date and shard partitions that are queried in Cassandra\n"+
+			"for submissions with submitted_at in [start, end).\n"+rangeHelp)
+	var timeRange rangeOptions
+	timeRange.addFlags(flags)
+	flags.Var(&envFlag{name: "genesis-ledger-file", env: "GENESIS_LEDGER_FILE"}, "genesis-ledger-file", "genesis ledger file used to convert --slots and --epochs (overrides GENESIS_LEDGER_FILE)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	startTime, endTime, err := timeRange.parse(flags.Args(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	shards := calculateShardsInRange(startTime, endTime)
+	fmt.Println(calculateDateRange(startTime, endTime))
+	fmt.Println(shardsToCql(shards))
+	fmt.Printf("%d shards\n", len(shards))
+	return nil
+}
+
+func runConfigCommand(ctx context.Context, args []string, log *logging.ZapEventLogger) error {
+	if len(args) == 0 || args[0] != "check" {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			fmt.Fprintln(os.Stderr, "Usage: submission-updater config check [flags]")
+			return flag.ErrHelp
+		}
+		return usageErrorf("expected subcommand: config check")
+	}
+	flags := newFlagSet("config check", "[flags]",
+		"Loads the configuration, prints it with secrets redacted and checks that the submission storage,\n"+
+			"the S3 bucket and the delegation-verify binary are reachable.")
+	addEnvFlags(flags)
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usageErrorf("unexpected arguments %v", flags.Args())
+	}
+
+	appCfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	redacted, err := json.MarshalIndent(redactConfig(appCfg), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(redacted))
+
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("%-20s FAILED: %v\n", name, err)
+			return
+		}
+		fmt.Printf("%-20s OK\n", name)
+	}
+
+	if appCfg.Verifier == VerifierSubprocess {
+		_, err = exec.LookPath(appCfg.DelegationVerifyBinPath)
+		check("delegation-verify", err)
+	}
+
+	appCtx, err := NewAppContext(ctx, appCfg, log)
+	check("connect", err)
+	if err == nil {
+		defer appCtx.Close()
+		check("submission storage", appCtx.Store.HealthCheck(ctx))
+		_, err = appCtx.S3Session.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(appCfg.AwsConfig.BucketName)})
+		check("s3 bucket", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d configuration checks failed", failed)
+	}
+	return nil
+}
+
+// redactConfig returns a copy of cfg with secrets replaced, suitable for printing.
+func redactConfig(cfg AppConfig) AppConfig {
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "REDACTED"
+	}
+	if cfg.AwsConfig != nil {
+		awsCfg := *cfg.AwsConfig
+		awsCfg.SecretAccessKey = redact(awsCfg.SecretAccessKey)
+		awsCfg.S3SecretAccessKey = redact(awsCfg.S3SecretAccessKey)
+		awsCfg.S3SessionToken = redact(awsCfg.S3SessionToken)
+		cfg.AwsConfig = &awsCfg
+	}
+	if cfg.CassandraConfig != nil {
+		cassandra := *cfg.CassandraConfig
+		cassandra.CassandraPassword = redact(cassandra.CassandraPassword)
+		cassandra.SecretAccessKey = redact(cassandra.SecretAccessKey)
+		cfg.CassandraConfig = &cassandra
+	}
+	if cfg.PostgreSQLConfig != nil {
+		postgres := *cfg.PostgreSQLConfig
+		postgres.Password = redact(postgres.Password)
+		cfg.PostgreSQLConfig = &postgres
+	}
+	if len(cfg.VerifierEnv) > 0 {
+		// environment entries may carry secrets, keep only the keys
+		env := make([]string, len(cfg.VerifierEnv))
+		for i, entry := range cfg.VerifierEnv {
+			key, value, _ := strings.Cut(entry, "=")
+			env[i] = key + "=" + redact(value)
+		}
+		cfg.VerifierEnv = env
+	}
+	return cfg
+}
+
+func runExportCommand(ctx context.Context, args []string, log *logging.ZapEventLogger) error {
+	flags := newFlagSet("export", "--output <file> [flags] <start> <end>",
+		"Exports the verification results currently stored for submissions with submitted_at\n"+
+			"in [start, end) to a JSONL or CSV report, without running verification.\n"+rangeHelp)
+	output := flags.String("output", "", "file the results are exported to (required)")
+	outputFormat := flags.String("output-format", "", "format of the --output file: jsonl or csv (default: derived from the file extension)")
+	var timeRange rangeOptions
+	timeRange.addFlags(flags)
+	addEnvFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return usageErrorf("--output is required")
+	}
+	startTime, endTime, err := timeRange.parse(flags.Args(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now().UTC()
+	appCfg, store, err := startStore(ctx, log)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	exporter, err := NewResultExporter(*output, *outputFormat)
+	if err != nil {
+		return err
+	}
+	defer exporter.Abort()
+
+	submissions, err := store.SelectRange(ctx, startTime, endTime)
+	if err != nil {
+		return fmt.Errorf("error selecting range: %w", err)
+	}
+	if err := exporter.Write(submissions); err != nil {
+		return fmt.Errorf("error exporting results: %w", err)
+	}
+
+	header := newRunHeader(appCfg, startTime, endTime, startedAt, len(submissions), nil)
+	header.VerifierPath = ""
+	for _, sub := range submissions {
+		if !sub.Verified && sub.ValidationError == "" {
+			// not verified yet
+			continue
+		}
+		if sub.ValidationError != "" || !sub.Verified {
+			header.Invalid++
+		} else {
+			header.Verified++
+		}
+	}
+	if err := exporter.Finish(header); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	log.Infof("Exported %d submissions to %s", len(submissions), *output)
+	return nil
+}
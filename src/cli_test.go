@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+func TestRunCLIExitCodes(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no arguments", args: nil, want: exitUsage},
+		{name: "help", args: []string{"help"}, want: exitOK},
+		{name: "command help", args: []string{"verify", "--help"}, want: exitOK},
+		{name: "unknown flag", args: []string{"verify", "--bogus"}, want: exitUsage},
+		{name: "missing end date", args: []string{"verify", "2024-03-11 00:00:00.0+0000"}, want: exitUsage},
+		{name: "legacy missing end date", args: []string{"2024-03-11 00:00:00.0+0000"}, want: exitUsage},
+		{name: "invalid date", args: []string{"shards", "yesterday", "2024-03-11 00:00:00.0+0000"}, want: exitUsage},
+		{name: "empty range", args: []string{"shards", "2024-03-11 00:00:00.0+0000", "2024-03-11 00:00:00.0+0000"}, want: exitUsage},
+		{name: "shards", args: []string{"shards", "2024-03-11 00:00:00.0+0000", "2024-03-11 00:02:24.0+0000"}, want: exitOK},
+		{name: "follow with dry run", args: []string{"verify", "--follow", "--dry-run"}, want: exitUsage},
+		{name: "reverify without id", args: []string{"reverify"}, want: exitUsage},
+		{name: "export without output", args: []string{"export", "2024-03-11 00:00:00.0+0000", "2024-03-11 01:00:00.0+0000"}, want: exitUsage},
+		{name: "config without check", args: []string{"config"}, want: exitUsage},
+	}
+
+	log := logging.Logger("test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := runCLI(context.Background(), tc.args, log); got != tc.want {
+				t.Errorf("runCLI(%q) = %d, want %d", tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunCLIInvalidFlagValues(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		env  string
+		want int
+	}{
+		{name: "concurrency", args: []string{"verify", "--concurrency", "0", "--since", "1h"}, env: "VERIFY_CONCURRENCY", want: exitUsage},
+		{name: "batch size", args: []string{"verify", "--batch-size", "-1", "--since", "1h"}, env: "VERIFY_BATCH_SIZE", want: exitUsage},
+		{name: "verify timeout", args: []string{"reverify", "--verify-timeout", "soon", "--id", "1"}, env: "DELEGATION_VERIFY_TIMEOUT", want: exitUsage},
+		{name: "block sources", args: []string{"export", "--block-sources", "ftp://blocks", "--output", "out.jsonl", "--since", "1h"}, env: "BLOCK_SOURCES", want: exitUsage},
+		{name: "config check", args: []string{"config", "check", "--storage", "BOGUS"}, env: "SUBMISSION_STORAGE", want: exitUsage},
+	}
+
+	log := logging.Logger("test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Restores the variable the flag sets once the test is done.
+			t.Setenv(tc.env, "")
+			// Not set, so that the flag is not the only invalid configuration.
+			t.Setenv("NETWORK_NAME", "")
+			if got := runCLI(context.Background(), tc.args, log); got != tc.want {
+				t.Errorf("runCLI(%q) = %d, want %d", tc.args, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("VERIFY_CONCURRENCY", "0")
+		t.Setenv("NETWORK_NAME", "testnet")
+		t.Setenv("AWS_S3_BUCKET", "test-bucket")
+		if got := runCLI(context.Background(), []string{"config", "check"}, log); got != exitFailure {
+			t.Errorf("runCLI() with invalid VERIFY_CONCURRENCY = %d, want %d", got, exitFailure)
+		}
+	})
+}
+
+// memoryStore is the submission storage selected with SUBMISSION_STORAGE=MEMORY in end-to-end tests.
+var memoryStore = &fakeStore{}
+
+func init() {
+	RegisterSubmissionStore("MEMORY", func(ctx context.Context, cfg AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+		return memoryStore, nil
+	})
+}
+
+func TestRunCLIEndToEnd(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	memoryStore.submissions = []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(time.Minute), Submitter: "B62a", BlockHash: "3NKa", RawBlock: RawBlock("block")},
+		// blocks missing in S3 are passed to the verifier as ""
+		{ID: "2", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(2 * time.Minute), Submitter: "B62b", BlockHash: "3NKb", RawBlock: RawBlock(`""`)},
+	}
+	memoryStore.updated = nil
+
+	dir := t.TempDir()
+	summaryFile := filepath.Join(dir, "summary.json")
+	t.Setenv("SUBMISSION_STORAGE", "MEMORY")
+	t.Setenv("DELEGATION_VERIFIER", "FAKE")
+	t.Setenv("NETWORK_NAME", "testnet")
+	t.Setenv("AWS_S3_BUCKET", "test-bucket")
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("RUN_SUMMARY_FILE", summaryFile)
+
+	args := []string{"verify", "--output", filepath.Join(dir, "results.jsonl"), "2024-03-11T00:00:00Z", "2024-03-11T01:00:00Z"}
+	if got := runCLI(context.Background(), args, logging.Logger("test")); got != exitOK {
+		t.Fatalf("runCLI(%q) = %d, want %d", args, got, exitOK)
+	}
+
+	if len(memoryStore.updated) != 2 {
+		t.Fatalf("runCLI() updated %d submissions, want 2", len(memoryStore.updated))
+	}
+	if got := memoryStore.updated[0]; got.ID != "1" || !got.Verified || got.StateHash != "3NKa" || got.VerifierVersion != fakeVerifierVersion {
+		t.Errorf("runCLI() updated %+v, want submission 1 verified", got)
+	}
+	if got := memoryStore.updated[1]; got.ID != "2" || got.Verified || got.ValidationError != fakeEmptyBlockError {
+		t.Errorf("runCLI() updated %+v, want submission 2 invalid", got)
+	}
+
+	data, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatalf("failed to read run summary: %v", err)
+	}
+	var summary RunSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("failed to parse run summary: %v", err)
+	}
+	if summary.Selected != 2 || summary.Verified != 1 || summary.Invalid != 1 || summary.MissingBlock != 1 {
+		t.Errorf("run summary = %+v, want 2 selected, 1 verified, 1 invalid, 1 missing block", summary)
+	}
+}
@@ -1,86 +1,398 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
+	"unicode"
+
+	logging "github.com/ipfs/go-log/v2"
 )
 
-func (ctx *AppContext) runDelegationVerifyCommand(command, input string) ([]Submission, error) {
-	var cmd string
+// commandWaitDelay is how long runCommand waits for the output pipes to be closed
+// after the command was killed or exited.
+const commandWaitDelay = 10 * time.Second
+
+// SubprocessVerifier verifies submissions by running the delegation-verify binary,
+// feeding it the batch on stdin and reading one result per line from stdout.
+type SubprocessVerifier struct {
+	BinPath           string
+	NoChecks          bool
+	GenesisLedgerFile string
+	// ExtraArgs are appended to the arguments of delegation-verify.
+	ExtraArgs []string
+	// Env are KEY=VALUE entries added to the environment inherited by delegation-verify.
+	Env []string
+	// Dir is the working directory of delegation-verify, the current one if empty.
+	Dir string
+	// VersionArgs are the arguments that make delegation-verify print its version.
+	VersionArgs []string
+	// OutputContract result records are checked against, nil to decode them leniently.
+	OutputContract *outputContract
+	// OutputPolicy decides what happens to records not conforming to OutputContract.
+	OutputPolicy string
+	// Quarantine receives non-conforming records with OutputPolicyQuarantine, optional.
+	Quarantine *QuarantineFile
+	Log        *logging.ZapEventLogger
+}
+
+func NewSubprocessVerifier(cfg AppConfig, log *logging.ZapEventLogger) (*SubprocessVerifier, error) {
+	contract, err := getOutputContract(cfg.VerifierOutputContract)
+	if err != nil {
+		return nil, err
+	}
+	v := &SubprocessVerifier{
+		BinPath:           cfg.DelegationVerifyBinPath,
+		NoChecks:          cfg.NoChecks,
+		GenesisLedgerFile: cfg.GenesisLedgerFile,
+		ExtraArgs:         cfg.VerifierExtraArgs,
+		Env:               cfg.VerifierEnv,
+		Dir:               cfg.VerifierDir,
+		VersionArgs:       cfg.VerifierVersionArgs,
+		OutputContract:    contract,
+		OutputPolicy:      cfg.VerifierOutputPolicy,
+		Log:               log,
+	}
+	if cfg.VerifierQuarantineFile != "" {
+		v.Quarantine = &QuarantineFile{Path: cfg.VerifierQuarantineFile}
+	}
+	if v.NoChecks {
+		log.Info("Note! Running with --no-checks flag. This will skip some checks.")
+	}
+	return v, nil
+}
 
-	// Start building the command
-	cmd = fmt.Sprintf("%v stdin", command)
+// args returns the arguments delegation-verify is run with.
+func (v *SubprocessVerifier) args() []string {
+	args := []string{"stdin"}
 
 	// Add --no-checks flag if needed
-	if ctx.AppConfig.NoChecks {
-		ctx.Log.Info("Note! Running with --no-checks flag. This will skip some checks.")
-		cmd = fmt.Sprintf("%s --no-checks", cmd)
+	if v.NoChecks {
+		args = append(args, "--no-checks")
 	}
 
 	// Add --config-file flag if ConfigFile is specified
-	if ctx.AppConfig.GenesisLedgerFile != "" {
-		cmd = fmt.Sprintf("%s --config-file %s", cmd, ctx.AppConfig.GenesisLedgerFile)
+	if v.GenesisLedgerFile != "" {
+		args = append(args, "--config-file", v.GenesisLedgerFile)
 	}
 
-	out, err := runCommand(cmd, input)
-	if err != nil {
-		return nil, fmt.Errorf("error running %v: %w", command, err)
-	}
+	return append(args, v.ExtraArgs...)
+}
 
-	submissions, err := parseDelegationVerifyOutput(out)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing submissions: %w", err)
+func (v *SubprocessVerifier) Verify(ctx context.Context, batch []Submission) ([]Submission, error) {
+	spec := commandSpec{Path: v.BinPath, Args: v.args(), Env: v.Env, Dir: v.Dir}
+
+	submissions := make([]Submission, 0, len(batch))
+	writeInput := func(w io.Writer) error {
+		return writeSubmissionsJSON(w, batch)
+	}
+	handleLine := func(line []byte) error {
+		submission, ok, err := parseDelegationVerifyLine(line, v.OutputContract)
+		var contractErr *OutputContractError
+		if errors.As(err, &contractErr) && v.OutputPolicy == OutputPolicyQuarantine {
+			return v.quarantine(line, submission, contractErr)
+		}
+		if err != nil {
+			return fmt.Errorf("error parsing submissions: %w", err)
+		}
+		if ok {
+			submissions = append(submissions, submission)
+		} else {
+			logVerifierLine(v.Log, v.BinPath, "stdout", line)
+		}
+		return nil
+	}
+	handleStderr := func(line []byte) {
+		logVerifierLine(v.Log, v.BinPath, "stderr", line)
+	}
+	if err := runCommand(ctx, spec, writeInput, handleLine, handleStderr); err != nil {
+		return nil, fmt.Errorf("error running %v: %w", v.BinPath, err)
 	}
 
 	return submissions, nil
 }
 
-// Output from the delegation verification binary is expected to be a newline-separated JSON array of Submission objects.
-// We parse this into a slice of Submission objects.
-func parseDelegationVerifyOutput(data string) ([]Submission, error) {
-	var submissions []Submission
+// versionTimeout bounds the run of delegation-verify printing its version.
+const versionTimeout = 30 * time.Second
 
-	// Split the input data into separate records based on newline.
-	records := strings.Split(data, "\n")
+// Version runs delegation-verify with VersionArgs and returns the first line it prints.
+func (v *SubprocessVerifier) Version(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
 
-	for _, record := range records {
-		if record == "" {
-			continue // Skip empty lines
-		}
-		// skip all lines that do not have submitted_at_date, which indicates optput is a submission
-		// and not a log line (when using --config-file flag, the output will contain additional log lines as well)
-		if !strings.Contains(record, "submitted_at_date") {
-			continue
+	var stdout, stderr []string
+	collect := func(lines *[]string) func([]byte) {
+		return func(line []byte) {
+			if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+				*lines = append(*lines, trimmed)
+			}
 		}
+	}
+	handleStdout := collect(&stdout)
+	spec := commandSpec{Path: v.BinPath, Args: v.VersionArgs, Env: v.Env, Dir: v.Dir}
+	err := runCommand(ctx, spec, func(io.Writer) error { return nil }, func(line []byte) error {
+		handleStdout(line)
+		return nil
+	}, collect(&stderr))
+	if err != nil {
+		return "", fmt.Errorf("error running %v %v: %w", v.BinPath, strings.Join(v.VersionArgs, " "), err)
+	}
+
+	// Some tools print their version to stderr.
+	if output := append(stdout, stderr...); len(output) > 0 {
+		return output[0], nil
+	}
+	return "", fmt.Errorf("%v %v printed no version", v.BinPath, strings.Join(v.VersionArgs, " "))
+}
+
+// writeSubmissionsJSON writes submissions to w as a JSON array, marshaling one submission at a time
+// so that the batch is never held in memory as a single JSON document.
+func writeSubmissionsJSON(w io.Writer, submissions []Submission) error {
+	bw := bufio.NewWriter(w)
 
-		var submission Submission
-		if err := json.Unmarshal([]byte(record), &submission); err != nil {
-			return nil, err // Return error if any record fails to unmarshal
+	if err := bw.WriteByte('['); err != nil {
+		return err
+	}
+	for i := range submissions {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(&submissions[i])
+		if err != nil {
+			return fmt.Errorf("error marshaling submission to JSON: %w", err)
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
 		}
+	}
+	if err := bw.WriteByte(']'); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Output from the delegation verification binary is expected to be newline-separated JSON Submission objects.
+// parseDelegationVerifyLine parses a single line of that output. It returns false for lines that
+// are not submissions. If contract is set, submissions are decoded strictly and an
+// *OutputContractError is returned for records that do not conform to it.
+func parseDelegationVerifyLine(line []byte, contract *outputContract) (Submission, bool, error) {
+	var submission Submission
 
-		submissions = append(submissions, submission)
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return submission, false, nil
 	}
 
-	return submissions, nil
+	// With a contract, every JSON object with a field of a result record is a result, so that
+	// records with a renamed submitted_at_date are rejected instead of being logged.
+	if contract != nil && trimmed[0] == '{' && contract.isRecord(trimmed) {
+		submission, err := contract.decode(line)
+		return submission, err == nil, err
+	}
+
+	// skip all lines that do not have submitted_at_date, which indicates optput is a submission
+	// and not a log line (when using --config-file flag, the output will contain additional log lines as well)
+	if contract != nil || !bytes.Contains(line, []byte("submitted_at_date")) {
+		return submission, false, nil
+	}
+	if err := json.Unmarshal(line, &submission); err != nil {
+		return submission, false, err
+	}
+	return submission, true, nil
 }
 
-func runCommand(command, input string) (string, error) {
-	cmdParts := strings.Split(command, " ")
-	cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
+// quarantine drops a result record that does not conform to the output contract,
+// logging it and appending it to the quarantine file if configured.
+func (v *SubprocessVerifier) quarantine(line []byte, submission Submission, reason *OutputContractError) error {
+	v.Log.Warnf("[QUARANTINE] Dropping verifier result for submission %s (submitter %s): %v",
+		submissionKey(submission), submission.Submitter, reason)
+	if v.Quarantine == nil {
+		return nil
+	}
+	if err := v.Quarantine.Add(v.BinPath, line, reason); err != nil {
+		return fmt.Errorf("error quarantining verifier result: %w", err)
+	}
+	return nil
+}
 
-	cmd.Stdin = bytes.NewBufferString(input)
+// commandSpec describes a command to run. Args are passed as is, without shell splitting.
+type commandSpec struct {
+	Path string
+	Args []string
+	// Env are KEY=VALUE entries added to the inherited environment.
+	Env []string
+	// Dir is the working directory, the current one if empty.
+	Dir string
+}
 
-	// Run the command and capture its standard output.
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
+// runCommand starts the command described by spec, streams its standard input from writeInput and calls handleLine
+// for every line of its standard output as soon as it is read. Lines passed to handleLine
+// are only valid until it returns. If handleLine fails the command is killed.
+// Every line of standard error is passed to handleStderr, and the last lines of it are
+// included in the returned error if the command fails.
+// The command runs in its own process group, which is killed when ctx is done.
+func runCommand(ctx context.Context, spec commandSpec, writeInput func(io.Writer) error, handleLine func([]byte) error, handleStderr func([]byte)) error {
+	cmd := exec.CommandContext(ctx, spec.Path, spec.Args...)
+	if len(spec.Env) > 0 {
+		cmd.Env = append(os.Environ(), spec.Env...)
+	}
+	cmd.Dir = spec.Dir
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// Do not wait forever for pipes held open by orphaned children.
+	cmd.WaitDelay = commandWaitDelay
 
-	err := cmd.Run()
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to run command: %w", err)
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+
+	inputErr := make(chan error, 1)
+	go func() {
+		err := writeInput(stdin)
+		if closeErr := stdin.Close(); err == nil {
+			err = closeErr
+		}
+		inputErr <- err
+	}()
+
+	tail := newLineTail(stderrTailLines)
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		_ = readLines(stderr, func(line []byte) error {
+			tail.add(line)
+			handleStderr(line)
+			return nil
+		})
+	}()
+
+	handleErr := readLines(stdout, handleLine)
+	if handleErr != nil {
+		_ = killProcessGroup(cmd)
+		_, _ = io.Copy(io.Discard, stdout)
 	}
+	writeErr := <-inputErr
+	<-stderrDone
 
-	return stdout.String(), nil
+	if err := cmd.Wait(); err != nil && handleErr == nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("command killed: %w", ctxErr)
+		}
+		if stderrTail := tail.String(); stderrTail != "" {
+			return fmt.Errorf("failed to run command: %w, stderr:\n%s", err, stderrTail)
+		}
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	if handleErr != nil {
+		return handleErr
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write command input: %w", writeErr)
+	}
+	return nil
+}
+
+// readLines calls handleLine for every line read from r, without the line terminator.
+func readLines(r io.Reader, handleLine func([]byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Long lines (e.g. results echoing a raw block) do not fit the buffer, collect them.
+			long := append([]byte(nil), line...)
+			var rest []byte
+			rest, err = reader.ReadBytes('\n')
+			line = append(long, rest...)
+		}
+		if len(line) > 0 {
+			if handleErr := handleLine(bytes.TrimRight(line, "\r\n")); handleErr != nil {
+				return handleErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read command output: %w", err)
+		}
+	}
+}
+
+// splitArgs splits s into arguments like a POSIX shell would, without any expansion:
+// arguments are separated by whitespace, single quotes preserve everything up to the
+// closing quote, double quotes and backslashes allow quoting whitespace and quotes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
 }
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command itself on platforms without process groups.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
@@ -1,50 +1,264 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRunCommand(t *testing.T) {
 	testCases := []struct {
 		name    string
-		command string
+		command commandSpec
 		input   string
-		want    string
+		want    []string
 		wantErr bool
 	}{
 		{
 			name:    "without input",
-			command: "echo -n",
+			command: commandSpec{Path: "echo", Args: []string{"-n"}},
 			input:   "",
-			want:    "",
+			want:    nil,
 			wantErr: false,
 		},
 		{
 			name:    "with input",
-			command: "cat",
+			command: commandSpec{Path: "cat"},
 			input:   "Hello",
-			want:    "Hello",
+			want:    []string{"Hello"},
+			wantErr: false,
+		},
+		{
+			name:    "multiple lines",
+			command: commandSpec{Path: "cat"},
+			input:   "first\nsecond\n\nthird\n",
+			want:    []string{"first", "second", "", "third"},
+			wantErr: false,
+		},
+		{
+			name:    "long line",
+			command: commandSpec{Path: "cat"},
+			input:   strings.Repeat("x", 100000) + "\n",
+			want:    []string{strings.Repeat("x", 100000)},
 			wantErr: false,
 		},
 		{
 			name:    "invalid command",
-			command: "nonexistentcommand",
+			command: commandSpec{Path: "nonexistentcommand"},
+			input:   "",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "failing command",
+			command: commandSpec{Path: "false"},
 			input:   "",
-			want:    "",
+			want:    nil,
 			wantErr: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := runCommand(tc.command, tc.input)
+			var got []string
+			writeInput := func(w io.Writer) error {
+				_, err := io.WriteString(w, tc.input)
+				return err
+			}
+			handleLine := func(line []byte) error {
+				got = append(got, string(line))
+				return nil
+			}
+			err := runCommand(context.Background(), tc.command, writeInput, handleLine, func([]byte) {})
 			if (err != nil) != tc.wantErr {
-				t.Errorf("runCommand(%q, %q) error = %v, wantErr %v", tc.command, tc.input, err, tc.wantErr)
+				t.Errorf("runCommand(%v, %q) error = %v, wantErr %v", tc.command, tc.input, err, tc.wantErr)
 				return
 			}
-			if !tc.wantErr && strings.TrimSpace(got) != tc.want {
-				t.Errorf("runCommand(%q, %q) = %q, want %q", tc.command, tc.input, got, tc.want)
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("runCommand(%v, %q) = %q, want %q", tc.command, tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunCommandArgsEnvDir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "with space")
+	script := "#!/bin/sh\nfor arg in \"$@\"; do echo \"arg:$arg\"; done\necho \"env:$VERIFIER_TEST_VAR\"\necho \"dir:$(pwd)\"\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	spec := commandSpec{
+		Path: path,
+		Args: []string{"stdin", "--config-file", "/tmp/genesis ledger.json"},
+		Env:  []string{"VERIFIER_TEST_VAR=a b"},
+		Dir:  dir,
+	}
+	var got []string
+	err := runCommand(context.Background(), spec, func(io.Writer) error { return nil }, func(line []byte) error {
+		got = append(got, string(line))
+		return nil
+	}, func([]byte) {})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+	want := []string{"arg:stdin", "arg:--config-file", "arg:/tmp/genesis ledger.json", "env:a b", "dir:" + dir}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runCommand() output = %q, want %q", got, want)
+	}
+}
+
+func TestSubprocessVerifierArgs(t *testing.T) {
+	v := &SubprocessVerifier{NoChecks: true, GenesisLedgerFile: "genesis.json", ExtraArgs: []string{"--max-block-age", "10"}}
+	want := []string{"stdin", "--no-checks", "--config-file", "genesis.json", "--max-block-age", "10"}
+	if got := v.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
+
+func TestSubprocessVerifierVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delegation_verify")
+	script := "#!/bin/sh\nif [ \"$1\" = \"-version\" ]; then echo; echo 'Commit abc123 on branch compatible'; exit 0; fi\nexit 1\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	v := &SubprocessVerifier{BinPath: path, VersionArgs: []string{"-version"}}
+	got, err := v.Version(context.Background())
+	if err != nil || got != "Commit abc123 on branch compatible" {
+		t.Errorf("Version() = %q, %v, want the first non-empty line", got, err)
+	}
+
+	v.VersionArgs = []string{"--unknown"}
+	if _, err := v.Version(context.Background()); err == nil {
+		t.Error("Version() error = nil, want error for failing command")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: "  --a  b ", want: []string{"--a", "b"}},
+		{input: `--path "/tmp/with space" 'single "quoted"'`, want: []string{"--path", "/tmp/with space", `single "quoted"`}},
+		{input: `a\ b ""`, want: []string{"a b", ""}},
+		{input: `KEY="x y"z`, want: []string{"KEY=x yz"}},
+		{input: `"unterminated`, wantErr: true},
+		{input: `trailing\`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := splitArgs(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRunCommandHandlerError(t *testing.T) {
+	handlerErr := errors.New("bad line")
+	writeInput := func(w io.Writer) error {
+		_, err := io.WriteString(w, "first\nsecond\n")
+		return err
+	}
+	handleLine := func(line []byte) error {
+		return handlerErr
+	}
+	if err := runCommand(context.Background(), commandSpec{Path: "cat"}, writeInput, handleLine, func([]byte) {}); !errors.Is(err, handlerErr) {
+		t.Errorf("runCommand() error = %v, want %v", err, handlerErr)
+	}
+}
+
+func TestRunCommandStderr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failing")
+	script := "#!/bin/sh\necho 'first problem' >&2\necho 'second problem' >&2\nexit 3\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	var stderr []string
+	err := runCommand(context.Background(), commandSpec{Path: path}, func(io.Writer) error { return nil }, func([]byte) error { return nil }, func(line []byte) {
+		stderr = append(stderr, string(line))
+	})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "first problem\nsecond problem") {
+		t.Errorf("runCommand() error = %v, want exit status and stderr tail", err)
+	}
+	if want := []string{"first problem", "second problem"}; !reflect.DeepEqual(stderr, want) {
+		t.Errorf("runCommand() stderr lines = %q, want %q", stderr, want)
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	// The script spawns a child that keeps stdout open, so the command only
+	// returns in time if the whole process group is killed.
+	path := filepath.Join(t.TempDir(), "hanging")
+	script := "#!/bin/sh\nsleep 30 &\nsleep 30\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err := runCommand(ctx, commandSpec{Path: path}, func(io.Writer) error { return nil }, func([]byte) error { return nil }, func([]byte) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("runCommand() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("runCommand() returned after %v, want the process group to be killed", elapsed)
+	}
+}
+
+func TestWriteSubmissionsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	submissions := []Submission{{ID: "1", RawBlock: RawBlock("block")}, {ID: "2"}}
+	if err := writeSubmissionsJSON(&buf, submissions); err != nil {
+		t.Fatalf("writeSubmissionsJSON() error = %v", err)
+	}
+
+	var got []Submission
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("writeSubmissionsJSON() wrote invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(got) != 2 || got[0].ID != "1" || got[1].ID != "2" {
+		t.Errorf("writeSubmissionsJSON() = %+v, want submissions 1 and 2", got)
+	}
+}
+
+func TestParseDelegationVerifyLine(t *testing.T) {
+	testCases := []struct {
+		name    string
+		line    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "submission", line: `{"id":"1","submitted_at_date":"2024-03-11","verified":true}`, wantOK: true},
+		{name: "empty line", line: ""},
+		{name: "log line", line: `{"level":"info","message":"loading config"}`},
+		{name: "malformed submission", line: `{"submitted_at_date":`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok, err := parseDelegationVerifyLine([]byte(tc.line), nil)
+			if (err != nil) != tc.wantErr || ok != tc.wantOK {
+				t.Errorf("parseDelegationVerifyLine(%q) = %v, %v, want ok %v, wantErr %v", tc.line, ok, err, tc.wantOK, tc.wantErr)
 			}
 		})
 	}
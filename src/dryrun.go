@@ -0,0 +1,68 @@
+package main
+
+import "time"
+
+// DryRunUpdate describes an update that would be written to the submission store,
+// together with the verification result that is currently stored.
+type DryRunUpdate struct {
+	Key                     string    `json:"key"`
+	Submitter               string    `json:"submitter"`
+	BlockHash               string    `json:"block_hash"`
+	SubmittedAt             time.Time `json:"submitted_at"`
+	StateHash               string    `json:"state_hash"`
+	Parent                  string    `json:"parent"`
+	Height                  int       `json:"height"`
+	Slot                    int       `json:"slot"`
+	Verified                bool      `json:"verified"`
+	ValidationError         string    `json:"validation_error"`
+	StoredVerified          bool      `json:"stored_verified"`
+	StoredValidationError   string    `json:"stored_validation_error"`
+	Changed                 bool      `json:"changed"`
+	NotFoundInSelectedRange bool      `json:"not_found_in_selected_range,omitempty"`
+}
+
+// newDryRunUpdates pairs verified submissions with the stored submissions they were produced from.
+func newDryRunUpdates(stored, verified []Submission) []DryRunUpdate {
+	storedByKey := make(map[string]Submission, len(stored))
+	for _, sub := range stored {
+		storedByKey[submissionKey(sub)] = sub
+	}
+
+	updates := make([]DryRunUpdate, 0, len(verified))
+	for _, sub := range verified {
+		key := submissionKey(sub)
+		update := DryRunUpdate{
+			Key:             key,
+			Submitter:       sub.Submitter,
+			BlockHash:       sub.BlockHash,
+			SubmittedAt:     sub.SubmittedAt,
+			StateHash:       sub.StateHash,
+			Parent:          sub.Parent,
+			Height:          sub.Height,
+			Slot:            sub.Slot,
+			Verified:        sub.Verified,
+			ValidationError: sub.ValidationError,
+		}
+		if original, found := storedByKey[key]; found {
+			update.StoredVerified = original.Verified
+			update.StoredValidationError = original.ValidationError
+		} else {
+			update.NotFoundInSelectedRange = true
+		}
+		update.Changed = update.Verified != update.StoredVerified || update.ValidationError != update.StoredValidationError
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// reportDryRun logs the updates that would be written for a batch, one record per submission.
+func (appCtx *AppContext) reportDryRun(batch, verifiedSubmissions []Submission) {
+	changed := 0
+	for _, update := range newDryRunUpdates(batch, verifiedSubmissions) {
+		if update.Changed {
+			changed++
+		}
+		appCtx.Log.Infow("[DRY-RUN] Would update submission", "update", update)
+	}
+	appCtx.Log.Infof("[DRY-RUN] %d of %d submissions would change their verification result", changed, len(verifiedSubmissions))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutputFormatJSONL = "jsonl"
+	OutputFormatCSV   = "csv"
+)
+
+// ResultRecord is a verified submission as exported to reports.
+// Raw block and snark work are left out to keep reports small.
+type ResultRecord struct {
+	ID                 string    `json:"id,omitempty"`
+	SubmittedAtDate    string    `json:"submitted_at_date"`
+	Shard              int       `json:"shard"`
+	SubmittedAt        time.Time `json:"submitted_at"`
+	Submitter          string    `json:"submitter"`
+	CreatedAt          time.Time `json:"created_at"`
+	BlockHash          string    `json:"block_hash"`
+	RemoteAddr         string    `json:"remote_addr"`
+	PeerID             string    `json:"peer_id"`
+	GraphqlControlPort int       `json:"graphql_control_port"`
+	BuiltWithCommitSha string    `json:"built_with_commit_sha"`
+	StateHash          string    `json:"state_hash"`
+	Parent             string    `json:"parent"`
+	Height             int       `json:"height"`
+	Slot               int       `json:"slot"`
+	Verified           bool      `json:"verified"`
+	ValidationError    string    `json:"validation_error"`
+}
+
+func newResultRecord(sub Submission) ResultRecord {
+	return ResultRecord{
+		ID:                 sub.ID,
+		SubmittedAtDate:    sub.SubmittedAtDate,
+		Shard:              sub.Shard,
+		SubmittedAt:        sub.SubmittedAt,
+		Submitter:          sub.Submitter,
+		CreatedAt:          sub.CreatedAt,
+		BlockHash:          sub.BlockHash,
+		RemoteAddr:         sub.RemoteAddr,
+		PeerID:             sub.PeerID,
+		GraphqlControlPort: sub.GraphqlControlPort,
+		BuiltWithCommitSha: sub.BuiltWithCommitSha,
+		StateHash:          sub.StateHash,
+		Parent:             sub.Parent,
+		Height:             sub.Height,
+		Slot:               sub.Slot,
+		Verified:           sub.Verified,
+		ValidationError:    sub.ValidationError,
+	}
+}
+
+var resultCSVColumns = []string{
+	"id", "submitted_at_date", "shard", "submitted_at", "submitter", "created_at", "block_hash",
+	"remote_addr", "peer_id", "graphql_control_port", "built_with_commit_sha",
+	"state_hash", "parent", "height", "slot", "verified", "validation_error",
+}
+
+func (r ResultRecord) csvRow() []string {
+	return []string{
+		r.ID, r.SubmittedAtDate, strconv.Itoa(r.Shard), r.SubmittedAt.UTC().Format(time.RFC3339Nano), r.Submitter,
+		r.CreatedAt.UTC().Format(time.RFC3339Nano), r.BlockHash, r.RemoteAddr, r.PeerID,
+		strconv.Itoa(r.GraphqlControlPort), r.BuiltWithCommitSha, r.StateHash, r.Parent,
+		strconv.Itoa(r.Height), strconv.Itoa(r.Slot), strconv.FormatBool(r.Verified), r.ValidationError,
+	}
+}
+
+// RunHeader describes the run a report was produced by.
+type RunHeader struct {
+	Type              string    `json:"type"`
+	WindowStart       time.Time `json:"window_start"`
+	WindowEnd         time.Time `json:"window_end"`
+	StartedAt         time.Time `json:"started_at"`
+	FinishedAt        time.Time `json:"finished_at"`
+	SubmissionStorage string    `json:"submission_storage"`
+	VerifierPath      string    `json:"verifier_path"`
+	VerifierVersion   string    `json:"verifier_version,omitempty"`
+	NoChecks          bool      `json:"no_checks"`
+	GenesisLedgerFile string    `json:"genesis_ledger_file,omitempty"`
+	VerifierArgs      []string  `json:"verifier_extra_args,omitempty"`
+	DryRun            bool      `json:"dry_run"`
+	Selected          int       `json:"selected"`
+	Verified          int       `json:"verified"`
+	Invalid           int       `json:"invalid"`
+	FailedBatches     int       `json:"failed_batches"`
+}
+
+// newRunHeader creates the header for a run over [startTime, endTime).
+// Counts are filled in from the batch results.
+func newRunHeader(cfg AppConfig, startTime, endTime, startedAt time.Time, selected int, results []BatchResult) RunHeader {
+	header := RunHeader{
+		Type:              "run_header",
+		WindowStart:       startTime,
+		WindowEnd:         endTime,
+		StartedAt:         startedAt,
+		FinishedAt:        time.Now().UTC(),
+		SubmissionStorage: cfg.SubmissionStorage,
+		VerifierPath:      cfg.DelegationVerifyBinPath,
+		NoChecks:          cfg.NoChecks,
+		GenesisLedgerFile: cfg.GenesisLedgerFile,
+		VerifierArgs:      cfg.VerifierExtraArgs,
+		DryRun:            cfg.DryRun,
+		Selected:          selected,
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			header.FailedBatches++
+			continue
+		}
+		header.Verified += result.Verified
+		header.Invalid += result.Invalid
+	}
+	return header
+}
+
+func (h RunHeader) csvComments() []string {
+	return []string{
+		"window_start: " + h.WindowStart.UTC().Format(time.RFC3339Nano),
+		"window_end: " + h.WindowEnd.UTC().Format(time.RFC3339Nano),
+		"started_at: " + h.StartedAt.UTC().Format(time.RFC3339Nano),
+		"finished_at: " + h.FinishedAt.UTC().Format(time.RFC3339Nano),
+		"submission_storage: " + h.SubmissionStorage,
+		"verifier_path: " + h.VerifierPath,
+		"verifier_version: " + h.VerifierVersion,
+		"no_checks: " + strconv.FormatBool(h.NoChecks),
+		"genesis_ledger_file: " + h.GenesisLedgerFile,
+		"verifier_extra_args: " + strings.Join(h.VerifierArgs, " "),
+		"dry_run: " + strconv.FormatBool(h.DryRun),
+		"selected: " + strconv.Itoa(h.Selected),
+		"verified: " + strconv.Itoa(h.Verified),
+		"invalid: " + strconv.Itoa(h.Invalid),
+		"failed_batches: " + strconv.Itoa(h.FailedBatches),
+	}
+}
+
+// outputFormat returns the report format for path: the explicitly requested one,
+// or one derived from the file extension, defaulting to JSONL.
+func outputFormat(path, format string) (string, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return OutputFormatCSV, nil
+		}
+		return OutputFormatJSONL, nil
+	}
+	if format != OutputFormatJSONL && format != OutputFormatCSV {
+		return "", fmt.Errorf("invalid output format %s, valid options are %s and %s", format, OutputFormatJSONL, OutputFormatCSV)
+	}
+	return format, nil
+}
+
+// ResultExporter writes verified submissions to a JSONL or CSV report.
+// Records are first collected in a temporary file, since the run header
+// at the top of the report is only known once the run is finished.
+// The report appears at its final path only after Finish succeeds.
+type ResultExporter struct {
+	path   string
+	format string
+	body   *os.File
+	buffer *bufio.Writer
+}
+
+// NewResultExporter creates an exporter writing to path in the given format.
+func NewResultExporter(path, format string) (*ResultExporter, error) {
+	format, err := outputFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".records-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	return &ResultExporter{path: path, format: format, body: body, buffer: bufio.NewWriter(body)}, nil
+}
+
+// Write appends verified submissions to the report.
+func (e *ResultExporter) Write(submissions []Submission) error {
+	if e.format == OutputFormatCSV {
+		writer := csv.NewWriter(e.buffer)
+		for _, sub := range submissions {
+			if err := writer.Write(newResultRecord(sub).csvRow()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	encoder := json.NewEncoder(e.buffer)
+	for _, sub := range submissions {
+		record := struct {
+			Type string `json:"type"`
+			ResultRecord
+		}{"submission", newResultRecord(sub)}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish writes the report with the given header to its final path.
+func (e *ResultExporter) Finish(header RunHeader) error {
+	defer e.Abort()
+	if err := e.buffer.Flush(); err != nil {
+		return fmt.Errorf("error writing output records: %w", err)
+	}
+	if _, err := e.body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(e.path), filepath.Base(e.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer os.Remove(out.Name())
+
+	writer := bufio.NewWriter(out)
+	if err := e.writeHeader(writer, header); err != nil {
+		out.Close()
+		return fmt.Errorf("error writing output header: %w", err)
+	}
+	if _, err := io.Copy(writer, e.body); err != nil {
+		out.Close()
+		return fmt.Errorf("error writing output records: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error closing output file: %w", err)
+	}
+	return os.Rename(out.Name(), e.path)
+}
+
+func (e *ResultExporter) writeHeader(w io.Writer, header RunHeader) error {
+	if e.format == OutputFormatCSV {
+		for _, comment := range header.csvComments() {
+			if _, err := fmt.Fprintf(w, "# %s\n", comment); err != nil {
+				return err
+			}
+		}
+		writer := csv.NewWriter(w)
+		if err := writer.Write(resultCSVColumns); err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return json.NewEncoder(w).Encode(header)
+}
+
+// Abort discards the records collected so far. It is safe to call after Finish.
+func (e *ResultExporter) Abort() {
+	e.body.Close()
+	os.Remove(e.body.Name())
+}
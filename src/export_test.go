@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputFormat(t *testing.T) {
+	testCases := []struct {
+		path    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{path: "results.jsonl", want: OutputFormatJSONL},
+		{path: "results.CSV", want: OutputFormatCSV},
+		{path: "results.txt", format: "csv", want: OutputFormatCSV},
+		{path: "results.csv", format: "JSONL", want: OutputFormatJSONL},
+		{path: "results.csv", format: "xml", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := outputFormat(tc.path, tc.format)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("outputFormat(%q, %q) error = %v, wantErr %v", tc.path, tc.format, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("outputFormat(%q, %q) = %q, want %q", tc.path, tc.format, got, tc.want)
+		}
+	}
+}
+
+func exportSubmissions(t *testing.T, path string) RunHeader {
+	t.Helper()
+	exporter, err := NewResultExporter(path, "")
+	if err != nil {
+		t.Fatalf("NewResultExporter() error = %v", err)
+	}
+	submissions := []Submission{
+		{ID: "1", Submitter: "B62a", RawBlock: RawBlock("block"), SnarkWork: []byte("work"), Verified: true},
+		{ID: "2", Submitter: "B62b", ValidationError: "bad, \"quoted\" proof"},
+	}
+	if err := exporter.Write(submissions); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	start := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	header := newRunHeader(AppConfig{DelegationVerifyBinPath: "/bin/delegation_verify"}, start, start.Add(time.Hour), start,
+		2, []BatchResult{{Submissions: 2, Verified: 1, Invalid: 1}})
+	if err := exporter.Finish(header); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("output directory contains %d files, want only the report", len(entries))
+	}
+	return header
+}
+
+func TestResultExporterJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	exportSubmissions(t, path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open report: %v", err)
+	}
+	defer file.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("report has %d lines, want 3", len(lines))
+	}
+	if lines[0]["type"] != "run_header" || lines[0]["selected"] != float64(2) || lines[0]["verified"] != float64(1) || lines[0]["invalid"] != float64(1) {
+		t.Errorf("header = %v, want run_header with counts", lines[0])
+	}
+	if lines[1]["type"] != "submission" || lines[1]["id"] != "1" {
+		t.Errorf("first record = %v, want submission 1", lines[1])
+	}
+	if _, found := lines[1]["raw_block"]; found {
+		t.Errorf("record contains raw_block")
+	}
+	if _, found := lines[1]["snark_work"]; found {
+		t.Errorf("record contains snark_work")
+	}
+}
+
+func TestResultExporterCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	exportSubmissions(t, path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# window_start: 2024-03-11T00:00:00Z\n") {
+		t.Errorf("report does not start with the run header: %q", string(data))
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("report has %d rows, want 3", len(rows))
+	}
+	if rows[0][0] != "id" || rows[2][len(rows[2])-1] != "bad, \"quoted\" proof" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+)
+
+const (
+	fakeEmptyBlockError = "fake verifier: empty raw_block"
+	fakeVerifierVersion = "fake"
+)
+
+// FakeVerifier is a deterministic in-process stand-in for delegation-verify, meant for local
+// runs and end-to-end tests without the OCaml tool. It returns a result for every submission:
+//   - submissions without a raw block (empty or "") are invalid with fakeEmptyBlockError
+//   - all other submissions are verified, with their block hash echoed as state hash
+type FakeVerifier struct{}
+
+func (FakeVerifier) Verify(ctx context.Context, batch []Submission) ([]Submission, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]Submission, 0, len(batch))
+	for _, sub := range batch {
+		result := sub
+		// Like delegation-verify, results do not carry the payloads.
+		result.RawBlock, result.SnarkWork = nil, nil
+		if isEmptyRawBlock(sub.RawBlock) {
+			result.StateHash, result.Parent, result.Height, result.Slot = "", "", 0, 0
+			result.ValidationError = fakeEmptyBlockError
+			result.Verified = false
+		} else {
+			result.StateHash = sub.BlockHash
+			result.ValidationError = ""
+			result.Verified = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (FakeVerifier) Version(ctx context.Context) (string, error) {
+	return fakeVerifierVersion, nil
+}
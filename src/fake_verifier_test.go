@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFakeVerifier(t *testing.T) {
+	batch := []Submission{
+		{ID: "1", BlockHash: "3NKa", RawBlock: RawBlock("block"), SnarkWork: []byte("work")},
+		{ID: "2", BlockHash: "3NKb"},
+		{ID: "3", BlockHash: "3NKc", RawBlock: RawBlock(`""`), StateHash: "stale", Verified: true},
+	}
+	want := []Submission{
+		{ID: "1", BlockHash: "3NKa", StateHash: "3NKa", Verified: true},
+		{ID: "2", BlockHash: "3NKb", ValidationError: fakeEmptyBlockError},
+		{ID: "3", BlockHash: "3NKc", ValidationError: fakeEmptyBlockError},
+	}
+
+	got, err := FakeVerifier{}.Verify(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() = %+v, want %+v", got, want)
+	}
+	if string(batch[0].RawBlock) != "block" {
+		t.Errorf("Verify() modified its input")
+	}
+}
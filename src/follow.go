@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxFollowRetryBackoff caps the delay between retries of a failed window.
+const maxFollowRetryBackoff = 30 * time.Minute
+
+// FileWatermark persists the high-water mark of follow mode in a local state file.
+// The mark is the end of the last window that was fully verified and updated,
+// so the next window always starts exactly where the previous one ended.
+// Together with the mark, the keys of the submissions of the next window that were
+// already written back are kept, so that an interrupted window is not written twice.
+type FileWatermark struct {
+	Path string
+}
+
+type watermarkState struct {
+	HighWaterMark time.Time `json:"high_water_mark"`
+	// Committed holds the keys of the submissions of the window following
+	// HighWaterMark that were already verified and written back.
+	Committed []string `json:"committed,omitempty"`
+}
+
+// Load returns the stored state. The boolean is false if nothing has been stored yet.
+func (w *FileWatermark) Load() (watermarkState, bool, error) {
+	data, err := os.ReadFile(w.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return watermarkState{}, false, nil
+	}
+	if err != nil {
+		return watermarkState{}, false, fmt.Errorf("error reading state file %s: %w", w.Path, err)
+	}
+
+	var state watermarkState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return watermarkState{}, false, fmt.Errorf("error parsing state file %s: %w", w.Path, err)
+	}
+	return state, true, nil
+}
+
+// Save stores the state. The state file is replaced atomically so that a crash
+// never leaves a truncated file behind.
+func (w *FileWatermark) Save(state watermarkState) error {
+	state.HighWaterMark = state.HighWaterMark.UTC()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.Path), filepath.Base(w.Path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), w.Path); err != nil {
+		return fmt.Errorf("error replacing state file %s: %w", w.Path, err)
+	}
+	return nil
+}
+
+// windowProgress records which submissions of a follow window have been written back.
+// It is saved to the state file after every written batch.
+type windowProgress struct {
+	watermark *FileWatermark
+	state     watermarkState
+	committed map[string]bool
+}
+
+func newWindowProgress(watermark *FileWatermark, state watermarkState) *windowProgress {
+	committed := make(map[string]bool, len(state.Committed))
+	for _, key := range state.Committed {
+		committed[key] = true
+	}
+	return &windowProgress{watermark: watermark, state: state, committed: committed}
+}
+
+// pending returns the submissions that have not been written back yet.
+func (p *windowProgress) pending(submissions []Submission) []Submission {
+	var pending []Submission
+	for _, sub := range submissions {
+		if !p.committed[submissionKey(sub)] {
+			pending = append(pending, sub)
+		}
+	}
+	return pending
+}
+
+// commit records a written batch and saves it to the state file.
+func (p *windowProgress) commit(batch []Submission) error {
+	for _, sub := range batch {
+		key := submissionKey(sub)
+		if !p.committed[key] {
+			p.committed[key] = true
+			p.state.Committed = append(p.state.Committed, key)
+		}
+	}
+	return p.watermark.Save(p.state)
+}
+
+// follow processes consecutive windows of FollowConfig.Window forever, starting at the
+// stored watermark (or initialStart if no watermark has been stored yet).
+// A window is processed only once its end is at least FollowConfig.Lateness in the past,
+// so that late submissions have a chance to be written first. Every written batch is
+// recorded in the state file and the watermark is advanced once the whole window has been
+// verified and updated, so a restart resumes with the submissions that were not written yet.
+// A failed window is retried with exponential backoff, and skipped after
+// FollowConfig.MaxAttempts attempts if that is set.
+func (appCtx *AppContext) follow(ctx context.Context, watermark *FileWatermark, initialStart time.Time) error {
+	log := appCtx.Log
+	cfg := appCtx.AppConfig.FollowConfig
+	if cfg.Window <= 0 {
+		return fmt.Errorf("follow window must be positive, got %v", cfg.Window)
+	}
+
+	attempts := 0
+	for {
+		state, found, err := watermark.Load()
+		if err != nil {
+			return err
+		}
+		if !found {
+			if initialStart.IsZero() {
+				return fmt.Errorf("no watermark stored in %s and no start date given", watermark.Path)
+			}
+			state = watermarkState{HighWaterMark: initialStart}
+		}
+		startTime := state.HighWaterMark
+		endTime := startTime.Add(cfg.Window)
+
+		if wait := time.Until(endTime.Add(cfg.Lateness)); wait > 0 {
+			log.Infof("Waiting %v for window (%v, %v) to close", wait.Round(time.Second), startTime.Format(dateLayout), endTime.Format(dateLayout))
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+		}
+
+		err = appCtx.resumeRange(ctx, startTime, endTime, newWindowProgress(watermark, state))
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			attempts++
+			if cfg.MaxAttempts <= 0 || attempts < cfg.MaxAttempts {
+				delay := followRetryDelay(cfg.RetryBackoff, attempts)
+				log.Errorf("Window (%v, %v) failed (attempt %d), retrying in %v: %v",
+					startTime.Format(dateLayout), endTime.Format(dateLayout), attempts, delay, err)
+				if err := sleepContext(ctx, delay); err != nil {
+					return err
+				}
+				continue
+			}
+			log.Errorf("Skipping window (%v, %v) after %d failed attempts, submissions not written back have to be reverified: %v",
+				startTime.Format(dateLayout), endTime.Format(dateLayout), attempts, err)
+		}
+
+		attempts = 0
+		if err := watermark.Save(watermarkState{HighWaterMark: endTime}); err != nil {
+			return err
+		}
+		log.Infof("Watermark advanced to %v", endTime.Format(dateLayout))
+	}
+}
+
+// followRetryDelay returns the delay before the given retry of a failed window.
+func followRetryDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempt && delay < maxFollowRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxFollowRetryBackoff && backoff <= maxFollowRetryBackoff {
+		delay = maxFollowRetryBackoff
+	}
+	return delay
+}
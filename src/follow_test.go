@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFileWatermark(t *testing.T) {
+	watermark := &FileWatermark{Path: filepath.Join(t.TempDir(), "state.json")}
+
+	if _, found, err := watermark.Load(); err != nil || found {
+		t.Fatalf("Load() on missing file = found %v, error %v, want not found", found, err)
+	}
+
+	mark := time.Date(2024, 3, 11, 10, 20, 0, 0, time.FixedZone("CET", 3600))
+	if err := watermark.Save(watermarkState{HighWaterMark: mark, Committed: []string{"1", "2"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, found, err := watermark.Load()
+	if err != nil || !found {
+		t.Fatalf("Load() = found %v, error %v, want found", found, err)
+	}
+	if !got.HighWaterMark.Equal(mark) || len(got.Committed) != 2 {
+		t.Errorf("Load() = %+v, want mark %v with 2 committed submissions", got, mark)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	windowStart := time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+	}}
+	watermark := &FileWatermark{Path: filepath.Join(t.TempDir(), "state.json")}
+	appCtx := newTestAppContext(store, AppConfig{
+		DelegationVerifyBinPath: writeFakeVerifier(t, `{"id":"1","submitted_at_date":"2024-03-11","verified":true}`),
+		FollowConfig:            &FollowConfig{Window: time.Hour},
+	})
+
+	// Two windows are already closed, the third one is still open
+	// so follow blocks until the context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err := appCtx.follow(ctx, watermark, windowStart)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("follow() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	state, _, err := watermark.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := windowStart.Add(2 * time.Hour); !state.HighWaterMark.Equal(want) || len(state.Committed) != 0 {
+		t.Errorf("watermark = %+v, want %v", state, want)
+	}
+	if len(store.updated) != 1 {
+		t.Errorf("follow() updated %d submissions, want 1", len(store.updated))
+	}
+}
+
+// flakyStore fails the update of the submissions in failing a number of times.
+type flakyStore struct {
+	fakeStore
+	failing map[string]int
+}
+
+func (store *flakyStore) UpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	for _, sub := range submissions {
+		if store.failing[sub.ID] > 0 {
+			store.failing[sub.ID]--
+			return fmt.Errorf("update of submission %s failed", sub.ID)
+		}
+	}
+	return store.fakeStore.UpdateSubmissions(ctx, submissions)
+}
+
+func TestFollowFailingWindow(t *testing.T) {
+	windowStart := time.Now().Add(-90 * time.Minute).Truncate(time.Minute)
+	submissions := []Submission{
+		{ID: "1", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+		{ID: "2", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(2 * time.Minute), RawBlock: RawBlock("block")},
+		{ID: "3", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(3 * time.Minute), RawBlock: RawBlock("block")},
+	}
+	tests := []struct {
+		name        string
+		failing     map[string]int
+		maxAttempts int
+		wantUpdated []string
+		wantMark    time.Time
+	}{
+		{
+			name:        "retried",
+			failing:     map[string]int{"2": 2},
+			wantUpdated: []string{"1", "2", "3"},
+			wantMark:    windowStart.Add(time.Hour),
+		},
+		{
+			name:        "skipped",
+			failing:     map[string]int{"2": 10},
+			maxAttempts: 3,
+			wantUpdated: []string{"1", "3"},
+			wantMark:    windowStart.Add(time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &flakyStore{fakeStore: fakeStore{submissions: submissions}, failing: tt.failing}
+			watermark := &FileWatermark{Path: filepath.Join(t.TempDir(), "state.json")}
+			appCtx := newTestAppContext(store, AppConfig{
+				Verifier:        VerifierFake,
+				VerifyBatchSize: 1,
+				FollowConfig:    &FollowConfig{Window: time.Hour, RetryBackoff: time.Millisecond, MaxAttempts: tt.maxAttempts},
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			if err := appCtx.follow(ctx, watermark, windowStart); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("follow() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			var updated []string
+			for _, sub := range store.updated {
+				updated = append(updated, sub.ID)
+			}
+			sort.Strings(updated)
+			if fmt.Sprint(updated) != fmt.Sprint(tt.wantUpdated) {
+				t.Errorf("updated submissions %v, want %v written once each", updated, tt.wantUpdated)
+			}
+			state, _, err := watermark.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !state.HighWaterMark.Equal(tt.wantMark) {
+				t.Errorf("watermark = %v, want %v", state.HighWaterMark, tt.wantMark)
+			}
+		})
+	}
+}
+
+func TestFollowResumesPartialWindow(t *testing.T) {
+	windowStart := time.Now().Add(-90 * time.Minute).Truncate(time.Minute)
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+		{ID: "2", SubmittedAtDate: windowStart.Format("2006-01-02"), SubmittedAt: windowStart.Add(2 * time.Minute), RawBlock: RawBlock("block")},
+	}}
+	watermark := &FileWatermark{Path: filepath.Join(t.TempDir(), "state.json")}
+	// a previous run wrote back submission 1 and was interrupted
+	if err := watermark.Save(watermarkState{HighWaterMark: windowStart, Committed: []string{"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	appCtx := newTestAppContext(store, AppConfig{
+		Verifier:     VerifierFake,
+		FollowConfig: &FollowConfig{Window: time.Hour},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := appCtx.follow(ctx, watermark, time.Time{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("follow() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(store.updated) != 1 || store.updated[0].ID != "2" {
+		t.Errorf("follow() updated %+v, want only submission 2", store.updated)
+	}
+}
+
+func TestFollowRetryDelay(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{backoff: time.Minute, attempt: 1, want: time.Minute},
+		{backoff: time.Minute, attempt: 3, want: 4 * time.Minute},
+		{backoff: time.Minute, attempt: 20, want: maxFollowRetryBackoff},
+		{backoff: time.Hour, attempt: 2, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := followRetryDelay(tt.backoff, tt.attempt); got != tt.want {
+			t.Errorf("followRetryDelay(%v, %d) = %v, want %v", tt.backoff, tt.attempt, got, tt.want)
+		}
+	}
+}
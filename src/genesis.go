@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultSlotDuration  = 3 * time.Minute
+	defaultSlotsPerEpoch = 7140
+)
+
+// GenesisConstants are the consensus constants needed to convert Mina slots and epochs to time.
+// Slots are global slots since the original genesis, as recorded in blocks. For networks
+// started from a hard fork GenesisTimestamp is the timestamp of the fork, which starts at
+// ForkSlot. Epochs are counted from GenesisTimestamp.
+type GenesisConstants struct {
+	GenesisTimestamp time.Time
+	SlotDuration     time.Duration
+	SlotsPerEpoch    int
+	ForkSlot         int
+}
+
+// genesisConfig is the part of the runtime config file (GENESIS_LEDGER_FILE) we read.
+type genesisConfig struct {
+	Genesis struct {
+		GenesisStateTimestamp string `json:"genesis_state_timestamp"`
+		SlotsPerEpoch         int    `json:"slots_per_epoch"`
+	} `json:"genesis"`
+	Proof struct {
+		BlockWindowDurationMs int `json:"block_window_duration_ms"`
+		Fork                  struct {
+			GlobalSlotSinceGenesis int `json:"global_slot_since_genesis"`
+		} `json:"fork"`
+	} `json:"proof"`
+}
+
+// LoadGenesisConstants reads the genesis timestamp, slot duration, epoch length and fork slot from
+// a runtime config file such as genesis_ledgers/mainnet.json. The slot duration and epoch length
+// default to the mainnet values if the file does not set them.
+func LoadGenesisConstants(path string) (GenesisConstants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GenesisConstants{}, fmt.Errorf("error reading genesis ledger file: %w", err)
+	}
+
+	var cfg genesisConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return GenesisConstants{}, fmt.Errorf("error parsing genesis ledger file %s: %w", path, err)
+	}
+	if cfg.Genesis.GenesisStateTimestamp == "" {
+		return GenesisConstants{}, fmt.Errorf("genesis ledger file %s does not set genesis.genesis_state_timestamp", path)
+	}
+	timestamp, err := time.Parse(time.RFC3339, cfg.Genesis.GenesisStateTimestamp)
+	if err != nil {
+		return GenesisConstants{}, fmt.Errorf("invalid genesis_state_timestamp in %s: %w", path, err)
+	}
+
+	constants := GenesisConstants{
+		GenesisTimestamp: timestamp.UTC(),
+		SlotDuration:     defaultSlotDuration,
+		SlotsPerEpoch:    defaultSlotsPerEpoch,
+		ForkSlot:         cfg.Proof.Fork.GlobalSlotSinceGenesis,
+	}
+	if constants.ForkSlot < 0 {
+		return GenesisConstants{}, fmt.Errorf("invalid proof.fork.global_slot_since_genesis in %s: %d", path, constants.ForkSlot)
+	}
+	if cfg.Proof.BlockWindowDurationMs > 0 {
+		constants.SlotDuration = time.Duration(cfg.Proof.BlockWindowDurationMs) * time.Millisecond
+	}
+	if cfg.Genesis.SlotsPerEpoch > 0 {
+		constants.SlotsPerEpoch = cfg.Genesis.SlotsPerEpoch
+	}
+	return constants, nil
+}
+
+// SlotStart returns the time the given global slot since genesis starts at.
+func (g GenesisConstants) SlotStart(slot int) time.Time {
+	return g.GenesisTimestamp.Add(time.Duration(slot-g.ForkSlot) * g.SlotDuration)
+}
+
+// SlotRange returns the time range [startTime, endTime) covering the slots first to last, inclusive.
+func (g GenesisConstants) SlotRange(first, last int) (startTime, endTime time.Time, err error) {
+	if first < 0 || last < first {
+		return startTime, endTime, fmt.Errorf("invalid slot range %d..%d", first, last)
+	}
+	if first < g.ForkSlot {
+		return startTime, endTime, fmt.Errorf("slot %d is before the fork at slot %d", first, g.ForkSlot)
+	}
+	return g.SlotStart(first), g.SlotStart(last + 1), nil
+}
+
+// EpochRange returns the time range [startTime, endTime) covering the epochs first to last, inclusive.
+func (g GenesisConstants) EpochRange(first, last int) (startTime, endTime time.Time, err error) {
+	if first < 0 || last < first {
+		return startTime, endTime, fmt.Errorf("invalid epoch range %d..%d", first, last)
+	}
+	return g.SlotRange(g.ForkSlot+first*g.SlotsPerEpoch, g.ForkSlot+(last+1)*g.SlotsPerEpoch-1)
+}
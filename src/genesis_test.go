@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadGenesisConstants(t *testing.T) {
+	genesis, err := LoadGenesisConstants("../genesis_ledgers/mainnet.json")
+	if err != nil {
+		t.Fatalf("LoadGenesisConstants() error = %v", err)
+	}
+	want := GenesisConstants{
+		GenesisTimestamp: time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC),
+		SlotDuration:     3 * time.Minute,
+		SlotsPerEpoch:    7140,
+		ForkSlot:         564480,
+	}
+	if genesis != want {
+		t.Errorf("LoadGenesisConstants() = %+v, want %+v", genesis, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "devnet.json")
+	config := `{"genesis": {"genesis_state_timestamp": "2024-04-09T21:00:00Z", "slots_per_epoch": 100},
+		"proof": {"block_window_duration_ms": 60000}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	genesis, err = LoadGenesisConstants(path)
+	if err != nil {
+		t.Fatalf("LoadGenesisConstants() error = %v", err)
+	}
+	if genesis.SlotDuration != time.Minute || genesis.SlotsPerEpoch != 100 || genesis.ForkSlot != 0 {
+		t.Errorf("LoadGenesisConstants() = %+v, want 1m slots, 100 slots per epoch and no fork", genesis)
+	}
+}
+
+func TestGenesisConstantsRanges(t *testing.T) {
+	genesis := GenesisConstants{
+		GenesisTimestamp: time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC),
+		SlotDuration:     3 * time.Minute,
+		SlotsPerEpoch:    7140,
+	}
+
+	start, end, err := genesis.SlotRange(10, 19)
+	if err != nil {
+		t.Fatalf("SlotRange() error = %v", err)
+	}
+	if !start.Equal(time.Date(2024, 6, 5, 0, 30, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 6, 5, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("SlotRange(10, 19) = (%v, %v)", start, end)
+	}
+
+	start, end, err = genesis.EpochRange(1, 1)
+	if err != nil {
+		t.Fatalf("EpochRange() error = %v", err)
+	}
+	if !start.Equal(genesis.SlotStart(7140)) || !end.Equal(genesis.SlotStart(14280)) {
+		t.Errorf("EpochRange(1, 1) = (%v, %v)", start, end)
+	}
+
+	if _, _, err := genesis.SlotRange(20, 10); err == nil {
+		t.Errorf("SlotRange(20, 10) succeeded, want error")
+	}
+}
+
+func TestGenesisConstantsForkRanges(t *testing.T) {
+	genesis, err := LoadGenesisConstants("../genesis_ledgers/mainnet.json")
+	if err != nil {
+		t.Fatalf("LoadGenesisConstants() error = %v", err)
+	}
+
+	if !genesis.SlotStart(564480).Equal(time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("SlotStart(564480) = %v, want the fork timestamp", genesis.SlotStart(564480))
+	}
+	start, end, err := genesis.SlotRange(564490, 564499)
+	if err != nil {
+		t.Fatalf("SlotRange() error = %v", err)
+	}
+	if !start.Equal(time.Date(2024, 6, 5, 0, 30, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 6, 5, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("SlotRange(564490, 564499) = (%v, %v)", start, end)
+	}
+
+	start, end, err = genesis.EpochRange(1, 1)
+	if err != nil {
+		t.Fatalf("EpochRange() error = %v", err)
+	}
+	if !start.Equal(time.Date(2024, 6, 19, 21, 0, 0, 0, time.UTC)) || !end.Equal(genesis.SlotStart(564480+14280)) {
+		t.Errorf("EpochRange(1, 1) = (%v, %v)", start, end)
+	}
+
+	if _, _, err := genesis.SlotRange(10, 19); err == nil {
+		t.Errorf("SlotRange(10, 19) before the fork succeeded, want error")
+	}
+}
+
+func TestRangeOptionsParseSlots(t *testing.T) {
+	t.Setenv("GENESIS_LEDGER_FILE", "../genesis_ledgers/mainnet.json")
+
+	opts := rangeOptions{slots: "564490..564499"}
+	start, end, err := opts.parse(nil, time.Now())
+	if err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	if !start.Equal(time.Date(2024, 6, 5, 0, 30, 0, 0, time.UTC)) || !end.Equal(time.Date(2024, 6, 5, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("parse() = (%v, %v)", start, end)
+	}
+
+	opts = rangeOptions{epochs: "0", since: "1h"}
+	if _, _, err := opts.parse(nil, time.Now()); err == nil {
+		t.Errorf("parse() with --epochs and --since succeeded, want error")
+	}
+}
@@ -2,9 +2,10 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -19,83 +20,99 @@ func main() {
 		File:   "",
 	})
 	log := logging.Logger("Submission Updater")
-	startTime, endTime := parseArgs(log)
 
-	appCfg := LoadEnv(log)
-	ctx := context.Background()
-
-	log.Info("Submission Updater started...")
-	log.Info("Using SUBMISSION_STORAGE: ", appCfg.SubmissionStorage)
-	log.Infof("Using DELEGATION_VERIFY_BIN_PATH: %v", appCfg.DelegationVerifyBinPath)
+	// Stop cleanly on SIGINT and SIGTERM (e.g. when Kubernetes terminates the pod):
+	// verifier processes are killed and batches in flight are not written back.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := runCLI(ctx, os.Args[1:], log)
+	stop()
+	os.Exit(code)
+}
 
-	appCtx, err := NewAppContext(ctx, appCfg, log)
-	if err != nil {
-		log.Fatalf("Error creating context: %v", err)
-	}
+// processRange selects submissions in [startTime, endTime) from the submission store,
+// runs delegation verification on them and writes the results back to the store.
+func (appCtx *AppContext) processRange(ctx context.Context, startTime, endTime time.Time) error {
+	return appCtx.resumeRange(ctx, startTime, endTime, nil)
+}
 
-	log.Infof("S3 session initialized")
-	log.Infof("Selecting submissions in range: (%v, %v)", startTime.Format("2006-01-02 15:04:05.0-0700"), endTime.Format("2006-01-02 15:04:05.0-0700"))
+// resumeRange is processRange for a window that may have been processed partially before.
+// If progress is not nil, submissions it holds are skipped and written batches are recorded in it.
+func (appCtx *AppContext) resumeRange(ctx context.Context, startTime, endTime time.Time, progress *windowProgress) error {
+	log := appCtx.Log
+	log.Infof("Selecting submissions in range: (%v, %v)", startTime.Format(dateLayout), endTime.Format(dateLayout))
 
-	submissions, err := appCtx.selectRange(startTime, endTime)
+	submissions, err := appCtx.Store.SelectRange(ctx, startTime, endTime)
 	if err != nil {
-		log.Fatalf("Error selecting range: %v", err)
+		return fmt.Errorf("error selecting range: %w", err)
 	}
-	numberOfReturnedSubmissions := len(submissions)
-	log.Infof("Number of returned submissions: %v", numberOfReturnedSubmissions)
-
-	if numberOfReturnedSubmissions == 0 {
-		log.Info("No submissions to verify")
-		os.Exit(0)
-	} else {
-		log.Info("Adding missing blocks from S3...")
-		submissions = appCtx.addMissingBlocksFromS3(ctx, submissions, appCfg)
-
-		log.Info("Running delegation verification...")
-		submissionsJSON, err := json.Marshal(submissions)
-		if err != nil {
-			log.Fatalf("Error marshaling submissions to JSON: %v", err)
+	if progress != nil {
+		pending := progress.pending(submissions)
+		if skipped := len(submissions) - len(pending); skipped > 0 {
+			log.Infof("Skipping %d submissions already written back by a previous attempt", skipped)
 		}
+		submissions = pending
+	}
+	return appCtx.processSubmissions(ctx, submissions, startTime, endTime, progress)
+}
 
-		// Run the delegation verification binary
-		verifiedSubmissions, err := appCtx.runDelegationVerifyCommand(appCfg.DelegationVerifyBinPath, string(submissionsJSON))
+// processSubmissions runs delegation verification on submissions selected for the window
+// [startTime, endTime) and writes the results back to the store.
+// Written batches are recorded in progress unless it is nil.
+func (appCtx *AppContext) processSubmissions(ctx context.Context, submissions []Submission, startTime, endTime time.Time, progress *windowProgress) error {
+	log := appCtx.Log
+	startedAt := time.Now().UTC()
+	summary := newRunSummary(appCtx.AppConfig, startTime, endTime)
+
+	var exporter *ResultExporter
+	if appCtx.AppConfig.Output != "" {
+		var err error
+		exporter, err = NewResultExporter(appCtx.AppConfig.Output, appCtx.AppConfig.OutputFormat)
 		if err != nil {
-			log.Fatalf("Error running command: %v", err)
+			return err
 		}
+		defer exporter.Abort()
+	}
 
-		// Update the submissions
-		err = appCtx.updateSubmissions(verifiedSubmissions)
-		if err != nil {
-			log.Fatalf("Error updating submissions: %v", err)
-		}
+	numberOfReturnedSubmissions := len(submissions)
+	log.Infof("Number of returned submissions: %v", numberOfReturnedSubmissions)
 
-		for _, sub := range verifiedSubmissions {
-			if sub.ValidationError != "" || !sub.Verified {
-				log.Infof("[INVALID] Submitter: %s, Block hash: %s, Submitted at: %s, Validation error: %s, Verified: %v",
-					sub.Submitter, sub.BlockHash, sub.SubmittedAt, sub.ValidationError, sub.Verified)
+	if numberOfReturnedSubmissions == 0 {
+		log.Info("No submissions to verify")
+		if exporter != nil {
+			header := newRunHeader(appCtx.AppConfig, startTime, endTime, startedAt, 0, nil)
+			header.VerifierVersion = appCtx.VerifierVersion
+			if err := exporter.Finish(header); err != nil {
+				return fmt.Errorf("error writing output file: %w", err)
 			}
 		}
+		return appCtx.reportSummary(summary)
 	}
-}
 
-func parseArgs(log logging.EventLogger) (startTime time.Time, endTime time.Time) {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: <program> <start date> <end date>")
-		os.Exit(1)
+	// The payloads are kept as selected, since addMissingBlocks replaces the raw blocks.
+	var payloads map[string]archivedPayloads
+	if appCtx.Archiver != nil {
+		payloads = appCtx.Archiver.selectPayloads(submissions)
 	}
 
-	startDate := os.Args[1]
-	endDate := os.Args[2]
+	log.Info("Adding missing blocks...")
+	submissions = appCtx.addMissingBlocks(ctx, submissions, appCtx.AppConfig)
+	summary.addSelected(submissions)
 
-	var err error
-	startTime, err = time.Parse("2006-01-02 15:04:05.0-0700", startDate)
-	if err != nil {
-		log.Fatalf("Error parsing start date:", err)
-	}
+	batches := splitIntoBatches(submissions, appCtx.AppConfig.VerifyBatchSize, appCtx.AppConfig.VerifyBatchMaxBytes)
+	log.Infof("Running delegation verification on %d submissions in %d batches with concurrency %d...",
+		numberOfReturnedSubmissions, len(batches), appCtx.AppConfig.VerifyConcurrency)
 
-	endTime, err = time.Parse("2006-01-02 15:04:05.0-0700", endDate)
-	if err != nil {
-		log.Fatalf("Error parsing end date:", err)
+	results := appCtx.processBatches(ctx, batches, payloads, exporter, summary, progress)
+	if exporter != nil {
+		header := newRunHeader(appCtx.AppConfig, startTime, endTime, startedAt, numberOfReturnedSubmissions, results)
+		header.VerifierVersion = appCtx.VerifierVersion
+		if err := exporter.Finish(header); err != nil {
+			return fmt.Errorf("error writing output file: %w", err)
+		}
+		log.Infof("Results written to %s", appCtx.AppConfig.Output)
 	}
-
-	return startTime, endTime
+	if err := appCtx.reportSummary(summary); err != nil {
+		return err
+	}
+	return summarizeBatches(log, results)
 }
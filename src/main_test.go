@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// fakeStore is an in-memory SubmissionStore used to test the pipeline without a database.
+type fakeStore struct {
+	submissions []Submission
+	updated     []Submission
+}
+
+func (store *fakeStore) SelectRange(ctx context.Context, startTime, endTime time.Time) ([]Submission, error) {
+	var result []Submission
+	for _, sub := range store.submissions {
+		if !sub.SubmittedAt.Before(startTime) && sub.SubmittedAt.Before(endTime) {
+			result = append(result, sub)
+		}
+	}
+	return result, nil
+}
+
+func (store *fakeStore) UpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	store.updated = append(store.updated, submissions...)
+	return nil
+}
+
+func (store *fakeStore) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (store *fakeStore) Close() error {
+	return nil
+}
+
+// newTestAppContext creates an AppContext using store and the verifier selected by cfg.
+func newTestAppContext(store SubmissionStore, cfg AppConfig) *AppContext {
+	log := logging.Logger("test")
+	verifier, err := NewVerifier(cfg, log)
+	if err != nil {
+		panic(err)
+	}
+	return &AppContext{Store: store, Verifier: verifier, AppConfig: cfg, Log: log, VerifierVersion: "test"}
+}
+
+// writeFakeVerifier writes a shell script that ignores its input and prints the given output.
+func writeFakeVerifier(t *testing.T, output string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "delegation_verify")
+	script := "#!/bin/sh\ncat > /dev/null\ncat <<'EOF'\n" + output + "\nEOF\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake verifier: %v", err)
+	}
+	return path
+}
+
+// writeEchoVerifier writes a shell script that marks every submission of its input as verified.
+// The input JSON array is turned into one record per line, as delegation-verify does.
+func writeEchoVerifier(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "delegation_verify")
+	script := "#!/bin/sh\nsed -e 's/^\\[//' -e 's/\\]$//' -e 's/},{/}\\n{/g' -e 's/\"verified\":false/\"verified\":true/g'\necho\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write echo verifier: %v", err)
+	}
+	return path
+}
+
+func TestProcessRange(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	windowEnd := windowStart.Add(time.Hour)
+
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+		{ID: "2", SubmittedAtDate: "2024-03-11", SubmittedAt: windowEnd.Add(time.Minute), RawBlock: RawBlock("block")},
+	}}
+	verifier := writeFakeVerifier(t, `{"id":"1","submitted_at_date":"2024-03-11","state_hash":"3NK","height":5,"verified":true}`)
+
+	appCtx := newTestAppContext(store, AppConfig{DelegationVerifyBinPath: verifier})
+	if err := appCtx.processRange(context.Background(), windowStart, windowEnd); err != nil {
+		t.Fatalf("processRange() error = %v", err)
+	}
+
+	if len(store.updated) != 1 {
+		t.Fatalf("processRange() updated %d submissions, want 1", len(store.updated))
+	}
+	got := store.updated[0]
+	if got.ID != "1" || got.StateHash != "3NK" || got.Height != 5 || !got.Verified {
+		t.Errorf("processRange() updated %+v, want verified submission 1", got)
+	}
+}
+
+func TestProcessRangeMarkUnreturned(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+		{ID: "2", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(2 * time.Minute), RawBlock: RawBlock("block")},
+	}}
+	verifier := writeFakeVerifier(t, `{"id":"1","submitted_at_date":"2024-03-11","state_hash":"3NK","height":5,"verified":true}`)
+
+	appCtx := newTestAppContext(store, AppConfig{DelegationVerifyBinPath: verifier, UnreturnedPolicy: UnreturnedPolicyMark})
+	if err := appCtx.processRange(context.Background(), windowStart, windowStart.Add(time.Hour)); err != nil {
+		t.Fatalf("processRange() error = %v", err)
+	}
+
+	if len(store.updated) != 2 {
+		t.Fatalf("processRange() updated %d submissions, want 2", len(store.updated))
+	}
+	if got := store.updated[1]; got.ID != "2" || got.Verified || got.ValidationError != unreturnedValidationError {
+		t.Errorf("processRange() updated %+v, want submission 2 marked as not returned", got)
+	}
+}
+
+func TestProcessRangeCancelled(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+	}}
+	appCtx := newTestAppContext(store, AppConfig{DelegationVerifyBinPath: writeEchoVerifier(t)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := appCtx.processRange(ctx, windowStart, windowStart.Add(time.Hour)); err == nil {
+		t.Fatal("processRange() error = nil, want error for cancelled context")
+	}
+	if len(store.updated) != 0 {
+		t.Errorf("processRange() updated %d submissions after cancellation, want 0", len(store.updated))
+	}
+}
+
+func TestProcessRangeEmpty(t *testing.T) {
+	store := &fakeStore{}
+	appCtx := newTestAppContext(store, AppConfig{DelegationVerifyBinPath: "nonexistentcommand"})
+	start := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	if err := appCtx.processRange(context.Background(), start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("processRange() error = %v", err)
+	}
+	if len(store.updated) != 0 {
+		t.Errorf("processRange() updated %d submissions, want 0", len(store.updated))
+	}
+}
+
+func TestProcessRangeConcurrentBatches(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{}
+	for i := 0; i < 10; i++ {
+		store.submissions = append(store.submissions, Submission{
+			ID:              strconv.Itoa(i),
+			SubmittedAtDate: "2024-03-11",
+			SubmittedAt:     windowStart.Add(time.Duration(i) * time.Minute),
+			RawBlock:        RawBlock("block"),
+		})
+	}
+
+	appCtx := newTestAppContext(store, AppConfig{
+		DelegationVerifyBinPath: writeEchoVerifier(t),
+		VerifyBatchSize:         3,
+		VerifyConcurrency:       4,
+	})
+	if err := appCtx.processRange(context.Background(), windowStart, windowStart.Add(time.Hour)); err != nil {
+		t.Fatalf("processRange() error = %v", err)
+	}
+
+	if len(store.updated) != len(store.submissions) {
+		t.Fatalf("processRange() updated %d submissions, want %d", len(store.updated), len(store.submissions))
+	}
+	for i, sub := range store.updated {
+		if sub.ID != strconv.Itoa(i) || !sub.Verified {
+			t.Errorf("update %d = submission %s (verified %v), want submission %d verified", i, sub.ID, sub.Verified, i)
+		}
+	}
+}
+
+func TestProcessRangeDryRun(t *testing.T) {
+	windowStart := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{submissions: []Submission{
+		{ID: "1", SubmittedAtDate: "2024-03-11", SubmittedAt: windowStart.Add(time.Minute), RawBlock: RawBlock("block")},
+	}}
+	appCtx := newTestAppContext(store, AppConfig{DelegationVerifyBinPath: writeEchoVerifier(t), DryRun: true})
+	if err := appCtx.processRange(context.Background(), windowStart, windowStart.Add(time.Hour)); err != nil {
+		t.Fatalf("processRange() error = %v", err)
+	}
+	if len(store.updated) != 0 {
+		t.Errorf("processRange() in dry-run mode updated %d submissions, want 0", len(store.updated))
+	}
+}
+
+func TestNewDryRunUpdates(t *testing.T) {
+	stored := []Submission{
+		{ID: "1", Verified: true},
+		{ID: "2", Verified: false, ValidationError: "old error"},
+	}
+	verified := []Submission{
+		{ID: "1", Verified: true},
+		{ID: "2", Verified: true},
+		{ID: "3", Verified: true},
+	}
+
+	updates := newDryRunUpdates(stored, verified)
+	if len(updates) != 3 {
+		t.Fatalf("newDryRunUpdates() returned %d updates, want 3", len(updates))
+	}
+	if updates[0].Changed {
+		t.Errorf("update of unchanged submission 1 marked as changed")
+	}
+	if !updates[1].Changed || updates[1].StoredValidationError != "old error" {
+		t.Errorf("update of submission 2 = %+v, want changed with stored error", updates[1])
+	}
+	if !updates[2].NotFoundInSelectedRange {
+		t.Errorf("update of unknown submission 3 not flagged as not found")
+	}
+}
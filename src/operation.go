@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -14,10 +16,14 @@ const (
 )
 
 // ExponentialBackoff retries the provided operation with an exponential backoff strategy.
-func ExponentialBackoff(operation Operation, maxRetries int, initialBackoff time.Duration) error {
+// It stops retrying and returns the error of ctx once ctx is done.
+func ExponentialBackoff(ctx context.Context, operation Operation, maxRetries int, initialBackoff time.Duration) error {
 	backoff := initialBackoff
 	var err error
 	for i := 0; i < maxRetries; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		err = operation()
 		if err == nil {
 			return nil // Success
@@ -25,10 +31,47 @@ func ExponentialBackoff(operation Operation, maxRetries int, initialBackoff time
 
 		if i < maxRetries-1 {
 			// If not the last retry, wait for a bit
-			time.Sleep(backoff)
+			if ctxErr := sleepContext(ctx, backoff); ctxErr != nil {
+				return ctxErr
+			}
 			backoff *= 2 // Exponential increase
 		}
 	}
 
 	return fmt.Errorf("operation failed after %d retries, returned error: %s", maxRetries, err)
 }
+
+// sleepContext waits for d or until ctx is done, in which case it returns the error of ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// forEachConcurrently calls f for 0 <= i < n with up to concurrency calls in parallel.
+func forEachConcurrently(n, concurrency int, f func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := ExponentialBackoff(ctx, func() error {
+		calls++
+		cancel()
+		return errors.New("failed")
+	}, 5, time.Hour)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ExponentialBackoff() error = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("operation called %d times, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Errorf("ExponentialBackoff() returned after %v, want it to stop waiting when ctx is done", elapsed)
+	}
+
+	calls = 0
+	err = ExponentialBackoff(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}, 5, time.Millisecond)
+	if err != nil || calls != 3 {
+		t.Errorf("ExponentialBackoff() = %v after %d calls, want success after 3", err, calls)
+	}
+}
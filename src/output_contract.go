@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Output contracts selectable with DELEGATION_VERIFY_OUTPUT_CONTRACT.
+const (
+	// OutputContractNone decodes verifier results leniently, without any checks.
+	OutputContractNone = "NONE"
+	// OutputContractV1 is the result record format of the current delegation-verify releases.
+	OutputContractV1 = "v1"
+)
+
+// Policies for verifier results that do not conform to the output contract.
+const (
+	// OutputPolicyError fails the batch.
+	OutputPolicyError = "ERROR"
+	// OutputPolicyQuarantine drops the record, logs it and appends it to the quarantine file.
+	// The submission is then handled as not returned by the verifier.
+	OutputPolicyQuarantine = "QUARANTINE"
+)
+
+var validOutputPolicies = []string{OutputPolicyError, OutputPolicyQuarantine}
+
+// outputContract describes the fields of a result record written by delegation-verify.
+type outputContract struct {
+	Version string
+	// Required fields have to be present in every record.
+	Required []string
+	// RequiredOneOf lists groups of fields of which at least one has to be present,
+	// e.g. the fields identifying the row in Postgres and Cassandra.
+	RequiredOneOf [][]string
+	// RequiredIfVerified fields have to be present in records with verified set.
+	RequiredIfVerified []string
+	// RequiredIfInvalid fields have to be present in records without verified set.
+	RequiredIfInvalid []string
+	// Allowed are all fields a record may have.
+	Allowed map[string]bool
+}
+
+var outputContracts = map[string]*outputContract{
+	OutputContractV1: {
+		Version:            OutputContractV1,
+		Required:           []string{"submitted_at_date", "submitted_at", "submitter", "verified"},
+		RequiredOneOf:      [][]string{{"id", "shard"}},
+		RequiredIfVerified: []string{"state_hash", "parent", "height", "slot"},
+		RequiredIfInvalid:  []string{"validation_error"},
+		Allowed: fieldSet(
+			"id", "submitted_at_date", "shard", "submitted_at", "submitter", "created_at", "block_hash",
+			"raw_block", "remote_addr", "peer_id", "snark_work", "graphql_control_port", "built_with_commit_sha",
+			"state_hash", "parent", "height", "slot", "validation_error", "verified",
+		),
+	},
+}
+
+func fieldSet(fields ...string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+// getOutputContract returns the contract with the given version, nil for OutputContractNone.
+func getOutputContract(version string) (*outputContract, error) {
+	if version == OutputContractNone || version == "" {
+		return nil, nil
+	}
+	contract, ok := outputContracts[version]
+	if !ok {
+		versions := []string{OutputContractNone}
+		for v := range outputContracts {
+			versions = append(versions, v)
+		}
+		sort.Strings(versions)
+		return nil, fmt.Errorf("unknown output contract %s, valid options are %v", version, versions)
+	}
+	return contract, nil
+}
+
+// OutputContractError is returned for verifier result records that do not conform to the output contract.
+type OutputContractError struct {
+	Version  string
+	Problems []string
+}
+
+func (e *OutputContractError) Error() string {
+	return fmt.Sprintf("verifier result does not conform to output contract %s: %s", e.Version, strings.Join(e.Problems, "; "))
+}
+
+// isRecord reports whether line is a JSON object with at least one field of a result record,
+// as opposed to a structured log line of the verifier.
+func (c *outputContract) isRecord(line []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return false
+	}
+	for field := range fields {
+		if c.Allowed[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// decode strictly decodes a result record, reporting unknown, missing and mistyped fields.
+func (c *outputContract) decode(line []byte) (Submission, error) {
+	var submission Submission
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return submission, &OutputContractError{Version: c.Version, Problems: []string{"invalid JSON object: " + err.Error()}}
+	}
+
+	var problems []string
+	var unknown []string
+	for field := range fields {
+		if !c.Allowed[field] {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		problems = append(problems, "unknown fields "+strings.Join(unknown, ", "))
+	}
+
+	if err := json.Unmarshal(line, &submission); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	missing := missingFields(fields, c.Required)
+	for _, group := range c.RequiredOneOf {
+		if len(missingFields(fields, group)) == len(group) {
+			missing = append(missing, strings.Join(group, " or "))
+		}
+	}
+	if _, ok := fields["verified"]; ok {
+		if submission.Verified {
+			missing = append(missing, missingFields(fields, c.RequiredIfVerified)...)
+		} else {
+			missing = append(missing, missingFields(fields, c.RequiredIfInvalid)...)
+		}
+	}
+	if len(missing) > 0 {
+		problems = append(problems, "missing fields "+strings.Join(missing, ", "))
+	}
+
+	if len(problems) > 0 {
+		return submission, &OutputContractError{Version: c.Version, Problems: problems}
+	}
+	return submission, nil
+}
+
+// missingFields returns the fields that are absent or null.
+func missingFields(fields map[string]json.RawMessage, required []string) []string {
+	var missing []string
+	for _, field := range required {
+		if value, ok := fields[field]; !ok || string(value) == "null" {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// QuarantineFile appends non-conforming verifier result records to a JSONL file.
+type QuarantineFile struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// quarantineRecord is a line of the quarantine file.
+type quarantineRecord struct {
+	Verifier string          `json:"verifier"`
+	Error    string          `json:"error"`
+	Record   json.RawMessage `json:"record,omitempty"`
+	Raw      string          `json:"raw,omitempty"`
+}
+
+// Add appends a record the verifier returned together with the reason it was quarantined.
+func (q *QuarantineFile) Add(verifier string, line []byte, reason error) error {
+	record := quarantineRecord{Verifier: verifier, Error: reason.Error()}
+	if json.Valid(line) {
+		record.Record = json.RawMessage(line)
+	} else {
+		record.Raw = string(line)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f, err := os.OpenFile(q.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening quarantine file: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing quarantine file: %w", err)
+	}
+	return f.Close()
+}
+
+func validateOutputPolicy(policy string) error {
+	for _, valid := range validOutputPolicies {
+		if policy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid output policy %s, valid options are %v", policy, validOutputPolicies)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+const (
+	conformingVerified = `{"id":"1","submitted_at_date":"2024-03-11","submitted_at":"2024-03-11T10:00:00Z","submitter":"B62a",` +
+		`"state_hash":"3NKa","parent":"3NKp","height":5,"slot":10,"verified":true,"validation_error":""}`
+	conformingInvalid = `{"submitted_at_date":"2024-03-11","shard":7,"submitted_at":"2024-03-11T10:00:00Z","submitter":"B62a",` +
+		`"verified":false,"validation_error":"block too old"}`
+)
+
+func TestOutputContractDecode(t *testing.T) {
+	testCases := []struct {
+		name        string
+		line        string
+		wantProblem string
+	}{
+		{name: "verified", line: conformingVerified},
+		{name: "invalid", line: conformingInvalid},
+		{name: "unknown field", line: strings.Replace(conformingVerified, `"height"`, `"block_height"`, 1), wantProblem: "unknown fields block_height"},
+		{name: "missing result field", line: strings.Replace(conformingVerified, `"state_hash":"3NKa",`, "", 1), wantProblem: "missing fields state_hash"},
+		{name: "null result field", line: strings.Replace(conformingVerified, `"3NKa"`, "null", 1), wantProblem: "missing fields state_hash"},
+		{name: "missing key", line: strings.Replace(conformingInvalid, `"shard":7,`, "", 1), wantProblem: "missing fields id or shard"},
+		{name: "missing validation error", line: strings.Replace(conformingInvalid, `,"validation_error":"block too old"`, "", 1), wantProblem: "missing fields validation_error"},
+		{name: "wrong type", line: strings.Replace(conformingVerified, `"height":5`, `"height":"5"`, 1), wantProblem: "cannot unmarshal string"},
+		{name: "malformed", line: `{"submitted_at_date":`, wantProblem: "invalid JSON object"},
+	}
+
+	contract := outputContracts[OutputContractV1]
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := contract.decode([]byte(tc.line))
+			if tc.wantProblem == "" {
+				if err != nil {
+					t.Errorf("decode() error = %v, want nil", err)
+				}
+				return
+			}
+			var contractErr *OutputContractError
+			if !errors.As(err, &contractErr) || !strings.Contains(err.Error(), tc.wantProblem) {
+				t.Errorf("decode() error = %v, want contract error containing %q", err, tc.wantProblem)
+			}
+		})
+	}
+}
+
+func TestSubprocessVerifierOutputPolicy(t *testing.T) {
+	nonConforming := strings.Replace(conformingInvalid, `"verified":false`, `"verified":false,"verdict":"invalid"`, 1)
+	output := conformingVerified + "\n" + nonConforming
+
+	t.Run("error", func(t *testing.T) {
+		v, err := NewSubprocessVerifier(AppConfig{
+			DelegationVerifyBinPath: writeFakeVerifier(t, output),
+			VerifierOutputContract:  OutputContractV1,
+			VerifierOutputPolicy:    OutputPolicyError,
+		}, logging.Logger("test"))
+		if err != nil {
+			t.Fatalf("NewSubprocessVerifier() error = %v", err)
+		}
+		var contractErr *OutputContractError
+		if _, err := v.Verify(context.Background(), nil); !errors.As(err, &contractErr) {
+			t.Errorf("Verify() error = %v, want *OutputContractError", err)
+		}
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		quarantineFile := filepath.Join(t.TempDir(), "quarantine.jsonl")
+		v, err := NewSubprocessVerifier(AppConfig{
+			DelegationVerifyBinPath: writeFakeVerifier(t, output),
+			VerifierOutputContract:  OutputContractV1,
+			VerifierOutputPolicy:    OutputPolicyQuarantine,
+			VerifierQuarantineFile:  quarantineFile,
+		}, logging.Logger("test"))
+		if err != nil {
+			t.Fatalf("NewSubprocessVerifier() error = %v", err)
+		}
+		results, err := v.Verify(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "1" {
+			t.Errorf("Verify() = %+v, want only the conforming result", results)
+		}
+
+		data, err := os.ReadFile(quarantineFile)
+		if err != nil {
+			t.Fatalf("failed to read quarantine file: %v", err)
+		}
+		var record quarantineRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatalf("invalid quarantine record %q: %v", data, err)
+		}
+		if !strings.Contains(record.Error, "unknown fields verdict") || string(record.Record) != nonConforming {
+			t.Errorf("quarantine record = %+v, want the non-conforming result and its error", record)
+		}
+	})
+}
+
+func TestParseDelegationVerifyLineWithContract(t *testing.T) {
+	contract := outputContracts[OutputContractV1]
+	renamed := strings.Replace(conformingVerified, `"submitted_at_date"`, `"submitted_at_day"`, 1)
+
+	testCases := []struct {
+		name    string
+		line    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "conforming", line: conformingVerified, wantOK: true},
+		{name: "renamed key field", line: renamed, wantErr: true},
+		{name: "log line", line: `{"timestamp":"2024-03-11 10:00:00.000000Z","level":"Info","message":"loading config"}`},
+		{name: "text line", line: `Loading genesis ledger`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok, err := parseDelegationVerifyLine([]byte(tc.line), contract)
+			var contractErr *OutputContractError
+			if ok != tc.wantOK || (err != nil) != tc.wantErr || (err != nil && !errors.As(err, &contractErr)) {
+				t.Errorf("parseDelegationVerifyLine(%q) = %v, %v, want ok %v, wantErr %v", tc.line, ok, err, tc.wantOK, tc.wantErr)
+			}
+		})
+	}
+}
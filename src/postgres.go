@@ -1,13 +1,36 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/lib/pq"
 )
 
+func init() {
+	RegisterSubmissionStore("POSTGRES", NewPostgresStore)
+}
+
+// PostgresStore is a SubmissionStore backed by the uptime-service-validation database.
+type PostgresStore struct {
+	DB  *sql.DB
+	Log *logging.ZapEventLogger
+	// StoreVerifierVersion enables writing the verifier_version column.
+	StoreVerifierVersion bool
+}
+
+// NewPostgresStore connects to PostgreSQL using config.PostgreSQLConfig.
+func NewPostgresStore(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+	db, err := InitializePostgresSession(config.PostgreSQLConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{DB: db, Log: log, StoreVerifierVersion: config.StoreVerifierVersion}, nil
+}
+
 func InitializePostgresSession(cfg *PostgreSQLConfig) (*sql.DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
@@ -21,16 +44,30 @@ func InitializePostgresSession(cfg *PostgreSQLConfig) (*sql.DB, error) {
 	return db, nil
 }
 
-func (ctx *AppContext) selectRangePostgres(startTime, endTime time.Time) ([]Submission, error) {
+func (store *PostgresStore) SelectRange(ctx context.Context, startTime, endTime time.Time) ([]Submission, error) {
 
-	query := `SELECT id, submitted_at_date, submitted_at, submitter, created_at, block_hash,
-              remote_addr, peer_id, snark_work, graphql_control_port, built_with_commit_sha
-              FROM submissions
+	query := postgresSelectColumns + `
               WHERE submitted_at >= $1 AND submitted_at < $2`
+	return store.selectSubmissions(ctx, query, startTime, endTime)
+}
+
+// SelectByIDs returns the submissions with the given IDs.
+func (store *PostgresStore) SelectByIDs(ctx context.Context, ids []string) ([]Submission, error) {
+	query := postgresSelectColumns + `
+              WHERE id::text = ANY($1)`
+	return store.selectSubmissions(ctx, query, pq.Array(ids))
+}
 
-	rows, err := ctx.PostgresSession.Query(query, startTime, endTime)
+const postgresSelectColumns = `SELECT id, submitted_at_date, submitted_at, submitter, created_at, block_hash,
+              remote_addr, peer_id, snark_work, graphql_control_port, built_with_commit_sha,
+              COALESCE(state_hash, ''), COALESCE(parent, ''), COALESCE(height, 0), COALESCE(slot, 0),
+              COALESCE(validation_error, ''), COALESCE(verified, false)
+              FROM submissions`
+
+func (store *PostgresStore) selectSubmissions(ctx context.Context, query string, args ...interface{}) ([]Submission, error) {
+	rows, err := store.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		ctx.Log.Errorf("Error executing query: %s", err)
+		store.Log.Errorf("Error executing query: %s", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -41,37 +78,54 @@ func (ctx *AppContext) selectRangePostgres(startTime, endTime time.Time) ([]Subm
 		if err := rows.Scan(&submission.ID, &submission.SubmittedAtDate, &submission.SubmittedAt,
 			&submission.Submitter, &submission.CreatedAt, &submission.BlockHash, &submission.RemoteAddr,
 			&submission.PeerID, &submission.SnarkWork, &submission.GraphqlControlPort,
-			&submission.BuiltWithCommitSha); err != nil {
-			ctx.Log.Errorf("Error scanning row: %s", err)
+			&submission.BuiltWithCommitSha, &submission.StateHash, &submission.Parent, &submission.Height,
+			&submission.Slot, &submission.ValidationError, &submission.Verified); err != nil {
+			store.Log.Errorf("Error scanning row: %s", err)
 			continue
 		}
 		submissions = append(submissions, submission)
 	}
 
 	if err := rows.Err(); err != nil {
-		ctx.Log.Errorf("Error iterating rows: %s", err)
+		store.Log.Errorf("Error iterating rows: %s", err)
 		return nil, err
 	}
 
 	return submissions, nil
 }
 
-func (ctx *AppContext) updateSubmissionsPostgres(submissions []Submission) error {
-	ctx.Log.Infof("Updating %d submissions", len(submissions))
+func (store *PostgresStore) UpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	store.Log.Infof("Updating %d submissions", len(submissions))
 
 	for _, sub := range submissions {
 		// We nullify snark_work to keep the space usage low
 		query := `UPDATE submissions
                   SET snark_work = NULL, state_hash = $1, parent = $2, height = $3, slot = $4, validation_error = $5, verified = $6
                   WHERE id = $7`
-		if _, err := ctx.PostgresSession.Exec(query,
-			sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
-			sub.ID); err != nil {
-			ctx.Log.Errorf("Failed to update submission: %v", err)
+		values := []interface{}{sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
+			sub.ID}
+		if store.StoreVerifierVersion {
+			query = `UPDATE submissions
+                  SET snark_work = NULL, state_hash = $1, parent = $2, height = $3, slot = $4, validation_error = $5, verified = $6,
+                  verifier_version = $7
+                  WHERE id = $8`
+			values = []interface{}{sub.StateHash, sub.Parent, sub.Height, sub.Slot, sub.ValidationError, sub.Verified,
+				sub.VerifierVersion, sub.ID}
+		}
+		if _, err := store.DB.ExecContext(ctx, query, values...); err != nil {
+			store.Log.Errorf("Failed to update submission: %v", err)
 			return err
 		}
 	}
 
-	ctx.Log.Infof("Submissions updated")
+	store.Log.Infof("Submissions updated")
 	return nil
 }
+
+func (store *PostgresStore) HealthCheck(ctx context.Context) error {
+	return store.DB.PingContext(ctx)
+}
+
+func (store *PostgresStore) Close() error {
+	return store.DB.Close()
+}
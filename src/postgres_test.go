@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// postgresFixture is a database/sql driver returning submissions rows as stored in the
+// uptime-service-validation submissions table for every query. It fails queries that do
+// not select every column of a row.
+type postgresFixture struct {
+	rows [][]driver.Value
+}
+
+var postgresFixtureColumns = []string{"id", "submitted_at_date", "submitted_at", "submitter", "created_at", "block_hash",
+	"remote_addr", "peer_id", "snark_work", "graphql_control_port", "built_with_commit_sha",
+	"state_hash", "parent", "height", "slot", "validation_error", "verified"}
+
+func (f *postgresFixture) Open(name string) (driver.Conn, error) { return f, nil }
+func (f *postgresFixture) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (f *postgresFixture) Close() error              { return nil }
+func (f *postgresFixture) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (f *postgresFixture) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	for _, column := range postgresFixtureColumns {
+		if !strings.Contains(query, column) {
+			return nil, errors.New("column " + column + " not selected")
+		}
+	}
+	return &postgresFixtureRows{rows: f.rows}, nil
+}
+
+type postgresFixtureRows struct {
+	rows [][]driver.Value
+}
+
+func (r *postgresFixtureRows) Columns() []string { return postgresFixtureColumns }
+func (r *postgresFixtureRows) Close() error      { return nil }
+func (r *postgresFixtureRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	submittedAt := time.Date(2024, 3, 11, 0, 1, 0, 0, time.UTC)
+	sql.Register("postgres-fixture", &postgresFixture{rows: [][]driver.Value{
+		{"1", "2024-03-11", submittedAt, "B62a", submittedAt, "3NKa", "1.2.3.4", "peer", nil, int64(3085), "abc",
+			"3NLa", "3NLp", int64(42), int64(7000), "", true},
+		{"2", "2024-03-11", submittedAt, "B62b", submittedAt, "3NKb", "1.2.3.5", "peer", nil, int64(3085), "abc",
+			"", "", int64(0), int64(0), "invalid block", false},
+	}})
+	RegisterSubmissionStore("POSTGRES_FIXTURE", func(ctx context.Context, cfg AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+		db, err := sql.Open("postgres-fixture", "")
+		if err != nil {
+			return nil, err
+		}
+		return &PostgresStore{DB: db, Log: log}, nil
+	})
+}
+
+func TestExportPostgres(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	t.Setenv("SUBMISSION_STORAGE", "POSTGRES_FIXTURE")
+	t.Setenv("NETWORK_NAME", "testnet")
+	t.Setenv("AWS_S3_BUCKET", "test-bucket")
+	t.Setenv("AWS_REGION", "us-west-2")
+	// export does not run the verifier, so it does not need one configured
+	t.Setenv("DELEGATION_VERIFIER", "")
+	t.Setenv("DELEGATION_VERIFY_BIN_PATH", "")
+
+	args := []string{"export", "--output", output, "2024-03-11T00:00:00Z", "2024-03-11T01:00:00Z"}
+	if got := runCLI(context.Background(), args, logging.Logger("test")); got != exitOK {
+		t.Fatalf("runCLI(%q) = %d, want %d", args, got, exitOK)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("export wrote %d lines, want header and 2 submissions", len(records))
+	}
+	if got := records[0]; got["verified"] != float64(1) || got["invalid"] != float64(1) {
+		t.Errorf("export header = %v, want 1 verified and 1 invalid", got)
+	}
+	if got := records[1]; got["state_hash"] != "3NLa" || got["parent"] != "3NLp" || got["height"] != float64(42) || got["slot"] != float64(7000) || got["verified"] != true {
+		t.Errorf("exported submission 1 = %v, want stored verification result", got)
+	}
+	if got := records[2]; got["validation_error"] != "invalid block" || got["verified"] != false {
+		t.Errorf("exported submission 2 = %v, want stored validation error", got)
+	}
+}
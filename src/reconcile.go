@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies for submissions the verifier returned no result for.
+const (
+	// UnreturnedPolicyIgnore leaves unreturned submissions untouched in the store.
+	UnreturnedPolicyIgnore = "IGNORE"
+	// UnreturnedPolicyMark writes unreturned submissions back as not verified
+	// with unreturnedValidationError.
+	UnreturnedPolicyMark = "MARK"
+)
+
+const unreturnedValidationError = "submission not returned by delegation verifier"
+
+var validUnreturnedPolicies = []string{UnreturnedPolicyIgnore, UnreturnedPolicyMark}
+
+// ReconcileReport lists the discrepancies between the submissions sent to the verifier
+// and the results it returned. Results are matched to inputs by submissionKey.
+type ReconcileReport struct {
+	// Missing are input submissions without a result.
+	Missing []Submission
+	// Duplicated are keys of input submissions with more than one result.
+	Duplicated []string
+	// Unexpected are results that do not match any input submission.
+	Unexpected []Submission
+}
+
+func (r ReconcileReport) empty() bool {
+	return len(r.Missing) == 0 && len(r.Duplicated) == 0 && len(r.Unexpected) == 0
+}
+
+// reconcileResults matches the verifier results to the input submissions.
+// It returns one result per matched input, in input order, dropping duplicated and
+// unexpected results, together with a report of all discrepancies.
+func reconcileResults(inputs, results []Submission) ([]Submission, ReconcileReport) {
+	var report ReconcileReport
+
+	resultsByKey := make(map[string][]Submission, len(results))
+	for _, result := range results {
+		key := submissionKey(result)
+		resultsByKey[key] = append(resultsByKey[key], result)
+	}
+
+	matched := make([]Submission, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		key := submissionKey(input)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		found := resultsByKey[key]
+		switch {
+		case len(found) == 0:
+			report.Missing = append(report.Missing, input)
+			continue
+		case len(found) > 1:
+			report.Duplicated = append(report.Duplicated, key)
+		}
+		matched = append(matched, found[0])
+	}
+
+	for _, result := range results {
+		if !seen[submissionKey(result)] {
+			report.Unexpected = append(report.Unexpected, result)
+		}
+	}
+	return matched, report
+}
+
+// markUnreturned returns the missing submissions of report marked as not verified,
+// ready to be written back to the store.
+func markUnreturned(report ReconcileReport) []Submission {
+	marked := make([]Submission, 0, len(report.Missing))
+	for _, sub := range report.Missing {
+		sub.Verified = false
+		sub.ValidationError = unreturnedValidationError
+		sub.StateHash, sub.Parent, sub.Height, sub.Slot = "", "", 0, 0
+		marked = append(marked, sub)
+	}
+	return marked
+}
+
+// reconcileBatch matches verifier results of a batch to its submissions, logs discrepancies
+// and applies the UnreturnedPolicy. It returns the submissions to be written back.
+func (appCtx *AppContext) reconcileBatch(index int, batch, results []Submission) ([]Submission, ReconcileReport) {
+	log := appCtx.Log
+	matched, report := reconcileResults(batch, results)
+	if report.empty() {
+		return matched, report
+	}
+
+	for _, sub := range report.Missing {
+		log.Warnf("[NOT RETURNED] Batch %d: verifier returned no result for submission %s (submitter %s, block hash %s)",
+			index, submissionKey(sub), sub.Submitter, sub.BlockHash)
+	}
+	if len(report.Duplicated) > 0 {
+		log.Warnf("[DUPLICATED] Batch %d: verifier returned more than one result for %d submissions, using the first one: %s",
+			index, len(report.Duplicated), strings.Join(report.Duplicated, ", "))
+	}
+	for _, sub := range report.Unexpected {
+		log.Warnf("[UNEXPECTED] Batch %d: verifier returned a result for unknown submission %s (submitter %s), ignoring it",
+			index, submissionKey(sub), sub.Submitter)
+	}
+
+	if appCtx.AppConfig.UnreturnedPolicy == UnreturnedPolicyMark && len(report.Missing) > 0 {
+		log.Infof("Batch %d: marking %d unreturned submissions as not verified", index, len(report.Missing))
+		matched = append(matched, markUnreturned(report)...)
+	}
+	return matched, report
+}
+
+func validateUnreturnedPolicy(policy string) error {
+	for _, valid := range validUnreturnedPolicies {
+		if policy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid unreturned policy %s, valid options are %v", policy, validUnreturnedPolicies)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReconcileResults(t *testing.T) {
+	submittedAt := time.Date(2024, 3, 11, 10, 15, 0, 0, time.UTC)
+	cassandra := func(submitter string) Submission {
+		return Submission{SubmittedAtDate: "2024-03-11", Shard: 7, SubmittedAt: submittedAt, Submitter: submitter}
+	}
+
+	testCases := []struct {
+		name           string
+		inputs         []Submission
+		results        []Submission
+		wantMatched    []Submission
+		wantMissing    []Submission
+		wantDuplicated []string
+		wantUnexpected []Submission
+	}{
+		{
+			name:        "all returned, reordered",
+			inputs:      []Submission{{ID: "1"}, {ID: "2"}},
+			results:     []Submission{{ID: "2", Verified: true}, {ID: "1", Verified: true}},
+			wantMatched: []Submission{{ID: "1", Verified: true}, {ID: "2", Verified: true}},
+		},
+		{
+			name:        "missing result",
+			inputs:      []Submission{{ID: "1"}, {ID: "2"}},
+			results:     []Submission{{ID: "2", Verified: true}},
+			wantMatched: []Submission{{ID: "2", Verified: true}},
+			wantMissing: []Submission{{ID: "1"}},
+		},
+		{
+			name:           "duplicated result keeps the first",
+			inputs:         []Submission{{ID: "1"}},
+			results:        []Submission{{ID: "1", Verified: true}, {ID: "1", ValidationError: "late"}},
+			wantMatched:    []Submission{{ID: "1", Verified: true}},
+			wantDuplicated: []string{"1"},
+		},
+		{
+			name:           "unexpected result",
+			inputs:         []Submission{{ID: "1"}},
+			results:        []Submission{{ID: "1", Verified: true}, {ID: "9", Verified: true}},
+			wantMatched:    []Submission{{ID: "1", Verified: true}},
+			wantUnexpected: []Submission{{ID: "9", Verified: true}},
+		},
+		{
+			name:           "cassandra key",
+			inputs:         []Submission{cassandra("B62a"), cassandra("B62b")},
+			results:        []Submission{cassandra("B62b"), cassandra("B62c")},
+			wantMatched:    []Submission{cassandra("B62b")},
+			wantMissing:    []Submission{cassandra("B62a")},
+			wantUnexpected: []Submission{cassandra("B62c")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched, report := reconcileResults(tc.inputs, tc.results)
+			if !reflect.DeepEqual(matched, tc.wantMatched) {
+				t.Errorf("matched = %+v, want %+v", matched, tc.wantMatched)
+			}
+			if !reflect.DeepEqual(report.Missing, tc.wantMissing) {
+				t.Errorf("Missing = %+v, want %+v", report.Missing, tc.wantMissing)
+			}
+			if !reflect.DeepEqual(report.Duplicated, tc.wantDuplicated) {
+				t.Errorf("Duplicated = %v, want %v", report.Duplicated, tc.wantDuplicated)
+			}
+			if !reflect.DeepEqual(report.Unexpected, tc.wantUnexpected) {
+				t.Errorf("Unexpected = %+v, want %+v", report.Unexpected, tc.wantUnexpected)
+			}
+		})
+	}
+}
+
+func TestMarkUnreturned(t *testing.T) {
+	report := ReconcileReport{Missing: []Submission{{ID: "1", Verified: true, StateHash: "hash", Height: 3}}}
+	want := []Submission{{ID: "1", ValidationError: unreturnedValidationError}}
+	if got := markUnreturned(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("markUnreturned() = %+v, want %+v", got, want)
+	}
+}
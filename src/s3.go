@@ -2,69 +2,165 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-func InitializeS3Session(ctx context.Context, region string) (*s3.Client, error) {
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+// defaultS3EndpointRegion is used to sign requests to custom endpoints if no region is set.
+const defaultS3EndpointRegion = "us-east-1"
+
+// InitializeS3Session creates an S3 client. By default AWS S3 is used with credentials
+// from the default credential chain; cfg can point the client at an S3-compatible
+// endpoint (e.g. MinIO or LocalStack) and provide static credentials instead.
+func InitializeS3Session(ctx context.Context, cfg *AwsConfig) (*s3.Client, error) {
+	region := cfg.Region
+	if region == "" && cfg.EndpointURL != "" {
+		region = defaultS3EndpointRegion
+	}
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if cfg.S3AccessKeyId != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.S3AccessKeyId, cfg.S3SecretAccessKey, cfg.S3SessionToken)))
+	}
+	if cfg.InsecureSkipVerify || cfg.CABundle != "" {
+		tlsConfig, err := newS3TLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig = tlsConfig
+		})
+		opts = append(opts, config.WithHTTPClient(httpClient))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("error loading AWS configuration: %w", err)
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(cfg.EndpointURL)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
 	return client, nil
 }
 
-func (appCtx *AppContext) addMissingBlocksFromS3(ctx context.Context, submissions []Submission, appCfg AppConfig) []Submission {
-	blockCache := make(map[string][]byte) // Cache for holding block data
+// newS3TLSConfig returns the TLS configuration for S3 connections, trusting
+// the certificates in cfg.CABundle in addition to the system ones.
+func newS3TLSConfig(cfg *AwsConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CABundle == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("error reading S3 CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("error reading S3 CA bundle: no certificates found in %s", cfg.CABundle)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// parseS3URL splits an s3://<bucket>/<prefix> URL. A non-empty prefix is returned with a trailing slash.
+func parseS3URL(s string) (bucket, prefix string, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", errors.New("expected s3://<bucket>/<prefix>")
+	}
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+// validateS3Config checks the S3 endpoint, TLS and credential settings.
+func validateS3Config(cfg *AwsConfig) error {
+	if cfg.EndpointURL != "" {
+		u, err := url.Parse(cfg.EndpointURL)
+		if err != nil {
+			return fmt.Errorf("invalid S3_ENDPOINT_URL: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid S3_ENDPOINT_URL %q: expected http(s)://host[:port]", cfg.EndpointURL)
+		}
+	}
+	if cfg.InsecureSkipVerify && cfg.CABundle != "" {
+		return errors.New("S3_TLS_INSECURE_SKIP_VERIFY and S3_CA_BUNDLE are mutually exclusive")
+	}
+	if cfg.CABundle != "" {
+		if _, err := newS3TLSConfig(cfg); err != nil {
+			return err
+		}
+	}
+	if (cfg.S3AccessKeyId == "") != (cfg.S3SecretAccessKey == "") {
+		return errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY have to be set together")
+	}
+	if cfg.S3SessionToken != "" && cfg.S3AccessKeyId == "" {
+		return errors.New("S3_SESSION_TOKEN requires S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	}
+	return nil
+}
 
-	for i, sub := range submissions {
-		if len(sub.RawBlock) == 0 {
-			// Check if the block is already in the cache
-			if rawBlock, found := blockCache[sub.BlockHash]; found {
-				submissions[i].RawBlock = rawBlock
-				continue
-			}
+// singleAttempt disables the retries of the S3 client for a call the caller retries itself
+// with ExponentialBackoff, so that the configured retries are the actual number of attempts.
+func singleAttempt(o *s3.Options) {
+	o.RetryMaxAttempts = 1
+}
 
-			// Block not in cache, download it from S3
-			blockPath := appCfg.NetworkName + "/blocks/" + sub.BlockHash + ".dat"
-			result, err := appCtx.S3Session.GetObject(ctx, &s3.GetObjectInput{
-				Bucket: aws.String(appCfg.AwsConfig.BucketName),
-				Key:    aws.String(blockPath),
-			})
-			if err != nil {
-				appCtx.Log.Errorf("Failed to get object for block hash %s from S3: %v", sub.BlockHash, err)
-				// Continue to next submission instead of stopping
-				continue
-			}
-			defer result.Body.Close()
+// S3BlockSource reads blocks from <Prefix><hash>.dat objects in an S3 bucket.
+type S3BlockSource struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
 
-			rawBlock, err := io.ReadAll(result.Body)
-			if err != nil {
-				appCtx.Log.Errorf("Failed to read body for block hash %s from S3 object: %v", sub.BlockHash, err)
-				// Continue to next submission instead of stopping
-				continue
-			}
+func (s *S3BlockSource) Name() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
 
-			// Update submission with the retrieved block
-			// if the block was successfully read
-			// if block not read, assign empty byte slice
-			// also cache the block data for future use
-			if len(rawBlock) > 0 {
-				blockCache[sub.BlockHash] = rawBlock
-				submissions[i].RawBlock = rawBlock
-			} else {
-				emptyBlock := []byte(`""`)
-				blockCache[sub.BlockHash] = emptyBlock
-				submissions[i].RawBlock = emptyBlock
-			}
-		}
+func (s *S3BlockSource) FetchBlock(ctx context.Context, hash string) ([]byte, error) {
+	result, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + hash + ".dat"),
+	}, singleAttempt)
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("%w: %v", errBlockNotFound, err)
 	}
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
 
-	return submissions
+	rawBlock, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body of S3 object: %w", err)
+	}
+	return rawBlock, nil
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+func init() {
+	RegisterSubmissionStore("S3", NewS3Store)
+}
+
+const (
+	// s3SubmissionSuffix ends the keys of submission objects written by the uptime service.
+	s3SubmissionSuffix = ".json"
+	// s3ResultSuffix ends the keys of the verification results written next to submission objects.
+	s3ResultSuffix = ".result.json"
+)
+
+// S3Store is a SubmissionStore reading the submission objects the uptime service writes to
+// <network>/submissions/<submitted_at_date>/<submitted_at>-<submitter>.json in an S3 bucket.
+// Verification results are written next to them as <submitted_at>-<submitter>.result.json,
+// the submission objects themselves are never modified. Submissions are identified by the
+// key of their object.
+type S3Store struct {
+	Client *s3.Client
+	Bucket string
+	// Prefix is the key prefix of the date partitions, <network>/submissions/.
+	Prefix string
+	// Concurrency is the number of objects read or written in parallel.
+	Concurrency int
+	Log         *logging.ZapEventLogger
+	// StoreVerifierVersion enables writing verifier_version to result objects.
+	StoreVerifierVersion bool
+}
+
+// s3SubmissionResult is the content of a result object.
+type s3SubmissionResult struct {
+	Submitter       string    `json:"submitter"`
+	SubmittedAt     time.Time `json:"submitted_at"`
+	BlockHash       string    `json:"block_hash"`
+	StateHash       string    `json:"state_hash"`
+	Parent          string    `json:"parent"`
+	Height          int       `json:"height"`
+	Slot            int       `json:"slot"`
+	ValidationError string    `json:"validation_error"`
+	Verified        bool      `json:"verified"`
+	VerifierVersion string    `json:"verifier_version,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// NewS3Store creates an S3 client using config.AwsConfig.
+func NewS3Store(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+	client, err := InitializeS3Session(ctx, config.AwsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{
+		Client:               client,
+		Bucket:               config.AwsConfig.BucketName,
+		Prefix:               config.NetworkName + "/submissions/",
+		Concurrency:          config.AwsConfig.StoreConcurrency,
+		Log:                  log,
+		StoreVerifierVersion: config.StoreVerifierVersion,
+	}, nil
+}
+
+// SelectRange lists the date partitions overlapping [startTime, endTime) and reads the
+// submission objects whose key falls into the range, together with their stored results.
+func (store *S3Store) SelectRange(ctx context.Context, startTime, endTime time.Time) ([]Submission, error) {
+	var keys []string
+	results := make(map[string]bool)
+	start := startTime.UTC()
+	end := endTime.UTC()
+	for day := start.Truncate(24 * time.Hour); day.Before(end); day = day.AddDate(0, 0, 1) {
+		prefix := store.Prefix + day.Format("2006-01-02") + "/"
+		paginator := s3.NewListObjectsV2Paginator(store.Client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(store.Bucket),
+			Prefix: aws.String(prefix),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error listing submissions under %s: %w", prefix, err)
+			}
+			for _, object := range page.Contents {
+				key := aws.ToString(object.Key)
+				if strings.HasSuffix(key, s3ResultSuffix) {
+					results[strings.TrimSuffix(key, s3ResultSuffix)+s3SubmissionSuffix] = true
+					continue
+				}
+				submittedAt, _, err := parseS3SubmissionKey(key)
+				if err != nil {
+					store.Log.Warnf("Skipping object %s: %v", key, err)
+					continue
+				}
+				if !submittedAt.Before(start) && submittedAt.Before(end) {
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+
+	withResults := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		withResults[key] = results[key]
+	}
+	return store.readSubmissions(ctx, keys, withResults)
+}
+
+// SelectByIDs reads the submission objects with the given keys.
+func (store *S3Store) SelectByIDs(ctx context.Context, ids []string) ([]Submission, error) {
+	withResults := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !strings.HasPrefix(id, store.Prefix) || !strings.HasSuffix(id, s3SubmissionSuffix) || strings.HasSuffix(id, s3ResultSuffix) {
+			return nil, fmt.Errorf("invalid submission key %s", id)
+		}
+		withResults[id] = true
+	}
+	return store.readSubmissions(ctx, ids, withResults)
+}
+
+// readSubmissions reads the given submission objects in parallel, and their result
+// objects if withResults is set for the key. Missing result objects are ignored.
+func (store *S3Store) readSubmissions(ctx context.Context, keys []string, withResults map[string]bool) ([]Submission, error) {
+	submissions := make([]Submission, len(keys))
+	errs := make([]error, len(keys))
+	forEachConcurrently(len(keys), store.Concurrency, func(i int) {
+		submissions[i], errs[i] = store.readSubmission(ctx, keys[i], withResults[keys[i]])
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func (store *S3Store) readSubmission(ctx context.Context, key string, withResult bool) (Submission, error) {
+	var sub Submission
+	found, err := store.getJSON(ctx, key, &sub)
+	if err != nil {
+		return Submission{}, fmt.Errorf("error reading submission %s: %w", key, err)
+	}
+	if !found {
+		return Submission{}, fmt.Errorf("submission %s not found", key)
+	}
+	// The key is authoritative for submitted_at and submitter, the body may lack them.
+	submittedAt, submitter, err := parseS3SubmissionKey(key)
+	if err != nil {
+		return Submission{}, fmt.Errorf("invalid submission key %s: %w", key, err)
+	}
+	if !sub.SubmittedAt.IsZero() && !sub.SubmittedAt.Equal(submittedAt) {
+		store.Log.Warnf("Submission %s has submitted_at %s, using %s from its key",
+			key, sub.SubmittedAt.Format(time.RFC3339Nano), submittedAt.Format(time.RFC3339Nano))
+	}
+	if sub.Submitter != "" && sub.Submitter != submitter {
+		store.Log.Warnf("Submission %s has submitter %s, using %s from its key", key, sub.Submitter, submitter)
+	}
+	sub.ID = key
+	sub.SubmittedAtDate = path.Base(path.Dir(key))
+	sub.SubmittedAt = submittedAt
+	sub.Submitter = submitter
+	// Blocks are stored separately and fetched from the block sources.
+	sub.RawBlock = nil
+
+	if withResult {
+		var result s3SubmissionResult
+		found, err := store.getJSON(ctx, s3ResultKey(key), &result)
+		if err != nil {
+			return Submission{}, fmt.Errorf("error reading result of submission %s: %w", key, err)
+		}
+		if found {
+			sub.StateHash = result.StateHash
+			sub.Parent = result.Parent
+			sub.Height = result.Height
+			sub.Slot = result.Slot
+			sub.ValidationError = result.ValidationError
+			sub.Verified = result.Verified
+			sub.VerifierVersion = result.VerifierVersion
+		}
+	}
+	return sub, nil
+}
+
+// getJSON reads the object with the given key into v, it returns false if the object does not exist.
+func (store *S3Store) getJSON(ctx context.Context, key string, v interface{}) (bool, error) {
+	output, err := store.Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(store.Bucket), Key: aws.String(key)})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer output.Body.Close()
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// UpdateSubmissions writes a result object for every submission.
+func (store *S3Store) UpdateSubmissions(ctx context.Context, submissions []Submission) error {
+	store.Log.Infof("Updating %d submissions", len(submissions))
+	updatedAt := time.Now().UTC()
+	errs := make([]error, len(submissions))
+	forEachConcurrently(len(submissions), store.Concurrency, func(i int) {
+		sub := submissions[i]
+		result := s3SubmissionResult{
+			Submitter:       sub.Submitter,
+			SubmittedAt:     sub.SubmittedAt,
+			BlockHash:       sub.BlockHash,
+			StateHash:       sub.StateHash,
+			Parent:          sub.Parent,
+			Height:          sub.Height,
+			Slot:            sub.Slot,
+			ValidationError: sub.ValidationError,
+			Verified:        sub.Verified,
+			UpdatedAt:       updatedAt,
+		}
+		if store.StoreVerifierVersion {
+			result.VerifierVersion = sub.VerifierVersion
+		}
+		errs[i] = ExponentialBackoff(ctx, func() error {
+			if err := store.putJSON(ctx, s3ResultKey(sub.ID), result); err != nil {
+				store.Log.Errorf("Error writing result of submission %s (trying again): %v", sub.ID, err)
+				return err
+			}
+			return nil
+		}, maxRetries, initialBackoff)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	store.Log.Infof("Submissions updated")
+	return nil
+}
+
+func (store *S3Store) putJSON(ctx context.Context, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = store.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(store.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}, singleAttempt)
+	return err
+}
+
+func (store *S3Store) HealthCheck(ctx context.Context) error {
+	_, err := store.Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(store.Bucket)})
+	return err
+}
+
+func (store *S3Store) Close() error {
+	return nil
+}
+
+// parseS3SubmissionKey returns submitted_at and submitter of a
+// .../<submitted_at>-<submitter>.json submission key.
+func parseS3SubmissionKey(key string) (time.Time, string, error) {
+	name := path.Base(key)
+	if !strings.HasSuffix(name, s3SubmissionSuffix) {
+		return time.Time{}, "", fmt.Errorf("not a submission object")
+	}
+	name = strings.TrimSuffix(name, s3SubmissionSuffix)
+	sep := strings.LastIndex(name, "-")
+	if sep < 0 {
+		return time.Time{}, "", fmt.Errorf("expected <submitted_at>-<submitter>.json")
+	}
+	submittedAt, err := time.Parse(time.RFC3339Nano, name[:sep])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid submitted_at: %w", err)
+	}
+	return submittedAt.UTC(), name[sep+1:], nil
+}
+
+// s3ResultKey returns the key of the result object of a submission object.
+func s3ResultKey(key string) string {
+	return strings.TrimSuffix(key, s3SubmissionSuffix) + s3ResultSuffix
+}
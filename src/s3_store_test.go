@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// fakeS3 is an in-memory S3 bucket supporting the requests used by S3Store.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+f.bucket), "/")
+	switch {
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key string
+		}
+		var result struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Name        string
+			Prefix      string
+			IsTruncated bool
+			Contents    []content
+		}
+		result.Name = f.bucket
+		result.Prefix = r.URL.Query().Get("prefix")
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, result.Prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result.Contents = append(result.Contents, content{Key: k})
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodHead:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	bucket := &fakeS3{bucket: "bucket", objects: map[string][]byte{
+		// written by the uptime service
+		"testnet/submissions/2024-03-10/2024-03-10T23:59:00Z-B62a.json": []byte(`{"submitted_at":"2024-03-10T23:59:00Z","submitter":"B62a","block_hash":"3NKa"}`),
+		"testnet/submissions/2024-03-11/2024-03-11T00:00:00Z-B62b.json": []byte(`{"submitted_at":"2024-03-11T00:00:00Z","submitter":"B62b","block_hash":"3NKb","peer_id":"peer","snark_work":"c25hcms=","created_at":"2024-03-11T00:00:01Z","graphql_control_port":3085,"built_with_commit_sha":"abc"}`),
+		"testnet/submissions/2024-03-11/2024-03-11T23:00:00Z-B62c.json": []byte(`{"block_hash":"3NKc"}`),
+		"testnet/submissions/2024-03-12/2024-03-12T00:30:00Z-B62d.json": []byte(`{"submitted_at":"2024-03-12T00:30:00Z","submitter":"B62d","block_hash":"3NKd"}`),
+		"testnet/submissions/2024-03-12/2024-03-12T01:00:00Z-B62e.json": []byte(`{"submitted_at":"2024-03-12T01:00:00Z","submitter":"B62e","block_hash":"3NKe"}`),
+		"testnet/submissions/2024-03-11/unrelated.txt":                  []byte("ignored"),
+		// stored result of a previous run
+		"testnet/submissions/2024-03-12/2024-03-12T00:30:00Z-B62d.result.json": []byte(`{"state_hash":"3NLd","verified":true,"height":5}`),
+	}}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	store := &S3Store{
+		Client:               newTestS3Client(server),
+		Bucket:               "bucket",
+		Prefix:               "testnet/submissions/",
+		Concurrency:          2,
+		Log:                  logging.Logger("test"),
+		StoreVerifierVersion: true,
+	}
+	ctx := context.Background()
+
+	got, err := store.SelectRange(ctx, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 12, 1, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, sub := range got {
+		ids = append(ids, sub.ID)
+	}
+	wantIDs := []string{
+		"testnet/submissions/2024-03-11/2024-03-11T00:00:00Z-B62b.json",
+		"testnet/submissions/2024-03-11/2024-03-11T23:00:00Z-B62c.json",
+		"testnet/submissions/2024-03-12/2024-03-12T00:30:00Z-B62d.json",
+	}
+	if strings.Join(ids, " ") != strings.Join(wantIDs, " ") {
+		t.Fatalf("SelectRange() returned %v, want %v", ids, wantIDs)
+	}
+	if sub := got[0]; sub.SubmittedAtDate != "2024-03-11" || sub.Submitter != "B62b" || sub.BlockHash != "3NKb" ||
+		string(sub.SnarkWork) != "snark" || sub.GraphqlControlPort != 3085 || !sub.SubmittedAt.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("SelectRange() returned %+v", sub)
+	}
+	if sub := got[1]; sub.Submitter != "B62c" || !sub.SubmittedAt.Equal(time.Date(2024, 3, 11, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("SelectRange() did not take submitted_at and submitter from the key: %+v", sub)
+	}
+	if sub := got[2]; !sub.Verified || sub.StateHash != "3NLd" || sub.Height != 5 {
+		t.Errorf("SelectRange() did not read stored result: %+v", sub)
+	}
+
+	got[0].Verified = true
+	got[0].StateHash = "3NLb"
+	got[0].VerifierVersion = "v1"
+	if err := store.UpdateSubmissions(ctx, got[:1]); err != nil {
+		t.Fatal(err)
+	}
+	var result s3SubmissionResult
+	if err := json.Unmarshal(bucket.objects["testnet/submissions/2024-03-11/2024-03-11T00:00:00Z-B62b.result.json"], &result); err != nil {
+		t.Fatalf("result object not written: %v", err)
+	}
+	if !result.Verified || result.StateHash != "3NLb" || result.VerifierVersion != "v1" || result.Submitter != "B62b" {
+		t.Errorf("result object = %+v", result)
+	}
+
+	byID, err := store.SelectByIDs(ctx, wantIDs[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byID) != 1 || !byID[0].Verified || byID[0].StateHash != "3NLb" {
+		t.Errorf("SelectByIDs() returned %+v", byID)
+	}
+	if _, err := store.SelectByIDs(ctx, []string{"testnet/submissions/2024-03-11/2024-03-11T00:00:00Z-B62b.result.json"}); err == nil {
+		t.Errorf("SelectByIDs() accepted a result object key")
+	}
+	if _, err := store.SelectByIDs(ctx, []string{"testnet/submissions/2024-03-11/2024-03-11T05:00:00Z-B62x.json"}); err == nil {
+		t.Errorf("SelectByIDs() accepted a missing submission")
+	}
+}
+
+func TestS3StorePutJSONSingleAttempt(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := &S3Store{Client: newRetryingTestS3Client(server), Bucket: "bucket", Log: logging.Logger("test")}
+	if err := store.putJSON(context.Background(), "key.result.json", s3SubmissionResult{}); err == nil {
+		t.Fatal("putJSON() succeeded, want error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("putJSON() sent %d requests, want 1", n)
+	}
+}
+
+func TestParseS3SubmissionKey(t *testing.T) {
+	tests := []struct {
+		key           string
+		wantTime      time.Time
+		wantSubmitter string
+		wantErr       bool
+	}{
+		{key: "net/submissions/2024-03-11/2024-03-11T10:00:00Z-B62a.json", wantTime: time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC), wantSubmitter: "B62a"},
+		{key: "net/submissions/2024-03-11/2024-03-11T10:00:00.5Z-B62a.json", wantTime: time.Date(2024, 3, 11, 10, 0, 0, 5e8, time.UTC), wantSubmitter: "B62a"},
+		{key: "net/submissions/2024-03-11/B62a.json", wantErr: true},
+		{key: "net/submissions/2024-03-11/2024-03-11T10:00:00Z-B62a.dat", wantErr: true},
+		{key: "net/submissions/2024-03-11/yesterday-B62a.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		submittedAt, submitter, err := parseS3SubmissionKey(tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseS3SubmissionKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (!submittedAt.Equal(tt.wantTime) || submitter != tt.wantSubmitter) {
+			t.Errorf("parseS3SubmissionKey(%q) = %v, %q, want %v, %q", tt.key, submittedAt, submitter, tt.wantTime, tt.wantSubmitter)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// newTestS3Client returns an S3 client talking to server with path-style addressing.
+func newTestS3Client(server *httptest.Server) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+// newRetryingTestS3Client returns a client for server that retries failed requests on its own,
+// like the clients created by InitializeS3Session.
+func newRetryingTestS3Client(server *httptest.Server) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      retry.NewStandard(),
+	})
+}
+
+// newTestS3BlockSource returns a source reading testnet blocks from bucket on server.
+func newTestS3BlockSource(server *httptest.Server) *S3BlockSource {
+	return &S3BlockSource{Client: newTestS3Client(server), Bucket: "bucket", Prefix: "testnet/blocks/"}
+}
+
+func TestAddMissingBlocksFromS3(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		requests[r.URL.Path]++
+		attempt := requests[r.URL.Path]
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/flaky.dat") && attempt == 1:
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasSuffix(r.URL.Path, "/missing.dat"):
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+		case strings.HasSuffix(r.URL.Path, "/empty.dat"):
+		default:
+			w.Write([]byte("block " + strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".dat")))
+		}
+	}))
+	defer server.Close()
+
+	appCfg := AppConfig{
+		NetworkName:            "testnet",
+		AwsConfig:              &AwsConfig{BucketName: "bucket"},
+		BlockSourceConcurrency: 3,
+		BlockSourceTimeout:     time.Second,
+		BlockSourceMaxRetries:  3,
+	}
+	appCtx := &AppContext{BlockSources: []BlockSource{newTestS3BlockSource(server)}, AppConfig: appCfg, Log: logging.Logger("test")}
+
+	submissions := []Submission{
+		{ID: "1", BlockHash: "a"},
+		{ID: "2", BlockHash: "b"},
+		{ID: "3", BlockHash: "a"},
+		{ID: "4", BlockHash: "flaky"},
+		{ID: "5", BlockHash: "missing"},
+		{ID: "6", BlockHash: "empty"},
+		{ID: "7", BlockHash: "c", RawBlock: RawBlock("stored")},
+		{ID: "8", BlockHash: "d"},
+		{ID: "9", BlockHash: "e"},
+	}
+	got := appCtx.addMissingBlocks(context.Background(), submissions, appCfg)
+
+	want := map[string]string{
+		"1": "block a", "2": "block b", "3": "block a", "4": "block flaky", "5": "",
+		"6": `""`, "7": "stored", "8": "block d", "9": "block e",
+	}
+	for _, sub := range got {
+		if string(sub.RawBlock) != want[sub.ID] {
+			t.Errorf("submission %s raw block = %q, want %q", sub.ID, sub.RawBlock, want[sub.ID])
+		}
+	}
+
+	if n := requests["/bucket/testnet/blocks/a.dat"]; n != 1 {
+		t.Errorf("block a requested %d times, want 1", n)
+	}
+	if n := requests["/bucket/testnet/blocks/flaky.dat"]; n != 2 {
+		t.Errorf("flaky block requested %d times, want 2", n)
+	}
+	if n := requests["/bucket/testnet/blocks/missing.dat"]; n != 1 {
+		t.Errorf("missing block requested %d times, want 1", n)
+	}
+	if _, ok := requests["/bucket/testnet/blocks/c.dat"]; ok {
+		t.Errorf("stored block c was requested")
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max < 2 || max > 3 {
+		t.Errorf("max concurrent requests = %d, want between 2 and 3", max)
+	}
+}
+
+func TestFetchBlockFromS3Attempts(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	source := &S3BlockSource{Client: newRetryingTestS3Client(server), Bucket: "bucket", Prefix: "testnet/blocks/"}
+	appCfg := AppConfig{BlockSourceMaxRetries: 2, BlockSourceTimeout: time.Second}
+	if _, err := fetchBlock(context.Background(), source, "a", appCfg); err == nil {
+		t.Fatal("fetchBlock() succeeded, want error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("fetchBlock() sent %d requests, want BLOCK_SOURCE_MAX_RETRIES (2)", n)
+	}
+}
+
+func TestValidateS3Config(t *testing.T) {
+	dir := t.TempDir()
+	invalidBundle := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidBundle, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cfg     AwsConfig
+		wantErr bool
+	}{
+		{name: "default"},
+		{name: "endpoint", cfg: AwsConfig{EndpointURL: "http://localhost:9000", UsePathStyle: true}},
+		{name: "static credentials", cfg: AwsConfig{S3AccessKeyId: "key", S3SecretAccessKey: "secret", S3SessionToken: "token"}},
+		{name: "skip verify", cfg: AwsConfig{EndpointURL: "https://minio:9000", InsecureSkipVerify: true}},
+		{name: "endpoint without scheme", cfg: AwsConfig{EndpointURL: "localhost:9000"}, wantErr: true},
+		{name: "endpoint without host", cfg: AwsConfig{EndpointURL: "http://"}, wantErr: true},
+		{name: "skip verify and CA bundle", cfg: AwsConfig{InsecureSkipVerify: true, CABundle: invalidBundle}, wantErr: true},
+		{name: "missing CA bundle", cfg: AwsConfig{CABundle: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "invalid CA bundle", cfg: AwsConfig{CABundle: invalidBundle}, wantErr: true},
+		{name: "access key without secret", cfg: AwsConfig{S3AccessKeyId: "key"}, wantErr: true},
+		{name: "secret without access key", cfg: AwsConfig{S3SecretAccessKey: "secret"}, wantErr: true},
+		{name: "session token without keys", cfg: AwsConfig{S3SessionToken: "token"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateS3Config(&tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateS3Config() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitializeS3SessionCustomEndpoint(t *testing.T) {
+	var authorization string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/testnet/blocks/a.dat" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("block a"))
+	}))
+	defer server.Close()
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caBundle, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     AwsConfig
+		wantErr bool
+	}{
+		{name: "CA bundle", cfg: AwsConfig{CABundle: caBundle}},
+		{name: "skip verify", cfg: AwsConfig{InsecureSkipVerify: true}},
+		{name: "untrusted certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorization = ""
+			cfg := tt.cfg
+			cfg.EndpointURL = server.URL
+			cfg.UsePathStyle = true
+			cfg.S3AccessKeyId = "minio"
+			cfg.S3SecretAccessKey = "minio-secret"
+			client, err := InitializeS3Session(context.Background(), &cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source := &S3BlockSource{Client: client, Bucket: "bucket", Prefix: "testnet/blocks/"}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			block, err := source.FetchBlock(ctx, "a")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(block) != "block a" {
+				t.Errorf("block = %q, want %q", block, "block a")
+			}
+			if !strings.Contains(authorization, "Credential=minio/") {
+				t.Errorf("request not signed with static credentials: %q", authorization)
+			}
+		})
+	}
+}
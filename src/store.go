@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// SubmissionStore is a backend where submissions are kept.
+// Submissions are selected from it for verification and
+// verification results are written back to it.
+type SubmissionStore interface {
+	// SelectRange returns submissions with submitted_at in [startTime, endTime).
+	SelectRange(ctx context.Context, startTime, endTime time.Time) ([]Submission, error)
+	// UpdateSubmissions writes verification results back to the store.
+	UpdateSubmissions(ctx context.Context, submissions []Submission) error
+	// HealthCheck returns an error if the store is not reachable.
+	HealthCheck(ctx context.Context) error
+	// Close releases resources held by the store.
+	Close() error
+}
+
+// SubmissionIDSelector is implemented by stores that can select individual submissions
+// by the key returned by submissionKey.
+type SubmissionIDSelector interface {
+	SelectByIDs(ctx context.Context, ids []string) ([]Submission, error)
+}
+
+// SubmissionStoreFactory creates a SubmissionStore from the application configuration.
+type SubmissionStoreFactory func(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error)
+
+// submissionStores maps SUBMISSION_STORAGE values to store factories.
+// Backends add themselves with RegisterSubmissionStore from init().
+var submissionStores = map[string]SubmissionStoreFactory{}
+
+// RegisterSubmissionStore makes a store backend available under the given
+// SUBMISSION_STORAGE name. It panics if the name is registered twice.
+func RegisterSubmissionStore(name string, factory SubmissionStoreFactory) {
+	if _, exists := submissionStores[name]; exists {
+		panic(fmt.Sprintf("submission store %s registered twice", name))
+	}
+	submissionStores[name] = factory
+}
+
+// NewSubmissionStore creates the store selected by config.SubmissionStorage.
+func NewSubmissionStore(ctx context.Context, config AppConfig, log *logging.ZapEventLogger) (SubmissionStore, error) {
+	factory, found := submissionStores[config.SubmissionStorage]
+	if !found {
+		return nil, fmt.Errorf("unknown submission storage %s, valid options are %v", config.SubmissionStorage, submissionStorageNames())
+	}
+	return factory(ctx, config, log)
+}
+
+// submissionStorageNames returns the sorted names of all registered store backends.
+func submissionStorageNames() []string {
+	names := make([]string, 0, len(submissionStores))
+	for name := range submissionStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -27,10 +28,26 @@ type Submission struct {
 	Slot               int       `json:"slot"`
 	ValidationError    string    `json:"validation_error"`
 	Verified           bool      `json:"verified"`
+	VerifierVersion    string    `json:"verifier_version,omitempty"`
+}
+
+// submissionKey returns a key identifying the row a submission belongs to.
+// Postgres rows are identified by ID, Cassandra rows by their primary key.
+func submissionKey(sub Submission) string {
+	if sub.ID != "" {
+		return sub.ID
+	}
+	return fmt.Sprintf("%s/%d/%s/%s", sub.SubmittedAtDate, sub.Shard, sub.SubmittedAt.UTC().Format(time.RFC3339Nano), sub.Submitter)
 }
 
 type RawBlock []byte
 
+// isEmptyRawBlock reports whether b holds no block. Blocks that could not be
+// found in S3 are set to "" (see addMissingBlocks).
+func isEmptyRawBlock(b RawBlock) bool {
+	return len(b) == 0 || string(b) == `""`
+}
+
 // Custom JSON marshalling for RawBlock type
 // This is necessary because the default marshalling of empty byte slice
 // is "null" instead of ""
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// topSubmittersLimit is the number of submitters listed in RunSummary.TopInvalidSubmitters.
+const topSubmittersLimit = 10
+
+// RunSummary aggregates the outcome of processing a window of submissions.
+type RunSummary struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DryRun      bool      `json:"dry_run"`
+
+	// Selected is the number of submissions selected from the store.
+	Selected int `json:"selected"`
+	// MissingBlock is the number of selected submissions for which no raw block could be found.
+	MissingBlock int `json:"missing_block"`
+	// Verified and Invalid are the numbers of verification results written back in successful
+	// batches that passed and failed verification. With UNRETURNED_POLICY=MARK the submissions
+	// marked as not returned are counted as invalid.
+	Verified int `json:"verified"`
+	Invalid  int `json:"invalid"`
+	// NotReturned is the number of submissions the verifier returned no result for.
+	NotReturned int `json:"not_returned_by_verifier"`
+	// Duplicated is the number of submissions the verifier returned more than one result for.
+	Duplicated int `json:"duplicated_by_verifier"`
+	// Unexpected is the number of results that did not match any submission sent to the verifier.
+	Unexpected int `json:"unexpected_from_verifier"`
+	// Poisoned is the number of submissions isolated as making the verifier fail.
+	Poisoned int `json:"poisoned"`
+	// FailedBatches and FailedSubmissions count batches that could not be verified or written back.
+	FailedBatches     int `json:"failed_batches"`
+	FailedSubmissions int `json:"failed_submissions"`
+
+	// ValidationErrors counts invalid submissions by normalised validation error.
+	ValidationErrors map[string]int `json:"validation_errors"`
+	// BuiltWithCommitSha counts valid and invalid submissions by the commit of the submitting node.
+	BuiltWithCommitSha map[string]*CommitCounts `json:"built_with_commit_sha"`
+	// TopInvalidSubmitters lists the submitters with most invalid submissions.
+	TopInvalidSubmitters []SubmitterCount `json:"top_invalid_submitters"`
+
+	invalidBySubmitter map[string]int
+}
+
+// CommitCounts are the submissions of nodes built from one commit that passed (Verified)
+// and failed (Invalid) verification.
+type CommitCounts struct {
+	Verified int `json:"verified"`
+	Invalid  int `json:"invalid"`
+}
+
+type SubmitterCount struct {
+	Submitter string `json:"submitter"`
+	Invalid   int    `json:"invalid"`
+}
+
+func newRunSummary(cfg AppConfig, startTime, endTime time.Time) *RunSummary {
+	return &RunSummary{
+		WindowStart:        startTime,
+		WindowEnd:          endTime,
+		StartedAt:          time.Now().UTC(),
+		DryRun:             cfg.DryRun,
+		ValidationErrors:   make(map[string]int),
+		BuiltWithCommitSha: make(map[string]*CommitCounts),
+		invalidBySubmitter: make(map[string]int),
+	}
+}
+
+// addSelected records the submissions selected for verification, after missing blocks have been added.
+func (s *RunSummary) addSelected(submissions []Submission) {
+	s.Selected += len(submissions)
+	for _, sub := range submissions {
+		if isEmptyRawBlock(sub.RawBlock) {
+			s.MissingBlock++
+		}
+	}
+}
+
+// addBatch records the outcome of a batch: the submissions sent to the verifier,
+// the submissions it returned and the error the batch failed with, if any.
+func (s *RunSummary) addBatch(batch, verifiedSubmissions []Submission, err error) {
+	if err != nil {
+		s.FailedBatches++
+		s.FailedSubmissions += len(batch)
+		return
+	}
+	for _, sub := range verifiedSubmissions {
+		commit := s.BuiltWithCommitSha[sub.BuiltWithCommitSha]
+		if commit == nil {
+			commit = &CommitCounts{}
+			s.BuiltWithCommitSha[sub.BuiltWithCommitSha] = commit
+		}
+		if sub.ValidationError != "" || !sub.Verified {
+			s.Invalid++
+			commit.Invalid++
+			s.ValidationErrors[normalizeValidationError(sub.ValidationError)]++
+			s.invalidBySubmitter[sub.Submitter]++
+		} else {
+			s.Verified++
+			commit.Verified++
+		}
+		if strings.HasPrefix(sub.ValidationError, poisonValidationErrorPrefix) {
+			s.Poisoned++
+		}
+	}
+}
+
+// addReconcileReport records the discrepancies between a batch and the verifier results.
+func (s *RunSummary) addReconcileReport(report ReconcileReport) {
+	s.NotReturned += len(report.Missing)
+	s.Duplicated += len(report.Duplicated)
+	s.Unexpected += len(report.Unexpected)
+}
+
+// finish computes the derived fields of the summary.
+func (s *RunSummary) finish() {
+	s.FinishedAt = time.Now().UTC()
+
+	s.TopInvalidSubmitters = make([]SubmitterCount, 0, len(s.invalidBySubmitter))
+	for submitter, invalid := range s.invalidBySubmitter {
+		s.TopInvalidSubmitters = append(s.TopInvalidSubmitters, SubmitterCount{Submitter: submitter, Invalid: invalid})
+	}
+	sort.Slice(s.TopInvalidSubmitters, func(i, j int) bool {
+		a, b := s.TopInvalidSubmitters[i], s.TopInvalidSubmitters[j]
+		if a.Invalid != b.Invalid {
+			return a.Invalid > b.Invalid
+		}
+		return a.Submitter < b.Submitter
+	})
+	if len(s.TopInvalidSubmitters) > topSubmittersLimit {
+		s.TopInvalidSubmitters = s.TopInvalidSubmitters[:topSubmittersLimit]
+	}
+}
+
+// writeFile writes the summary as JSON to path.
+func (s *RunSummary) writeFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing run summary: %w", err)
+	}
+	return nil
+}
+
+var (
+	// hashPattern matches base58/hex encoded hashes and keys.
+	hashPattern   = regexp.MustCompile(`[0-9A-Za-z]{20,}`)
+	numberPattern = regexp.MustCompile(`\d+`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// normalizeValidationError strips the submission specific parts (hashes, keys, numbers)
+// from a validation error, so that errors of the same kind are counted together.
+func normalizeValidationError(validationError string) string {
+	if validationError == "" {
+		return "(not verified, no validation error)"
+	}
+	normalized := hashPattern.ReplaceAllString(validationError, "<hash>")
+	normalized = numberPattern.ReplaceAllString(normalized, "<n>")
+	normalized = spacePattern.ReplaceAllString(normalized, " ")
+	return strings.TrimSpace(normalized)
+}
+
+// reportSummary emits the summary as a single log record and writes it to RunSummaryFile if configured.
+func (appCtx *AppContext) reportSummary(summary *RunSummary) error {
+	summary.finish()
+	appCtx.Log.Infow("Run summary", "summary", summary)
+	if appCtx.AppConfig.RunSummaryFile != "" {
+		return summary.writeFile(appCtx.AppConfig.RunSummaryFile)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeValidationError(t *testing.T) {
+	testCases := []struct {
+		validationError string
+		want            string
+	}{
+		{"", "(not verified, no validation error)"},
+		{"Invalid block: 3NKeMoncuHab5ScarV5ViyF16cJPT4taWNSaTLS64Dp67wuXigPZ", "Invalid block: <hash>"},
+		{"block at height 42 is   too old", "block at height <n> is too old"},
+		{"proof failed for B62qrPN5Y5yq8kGE3FbVKbGTdTAJNdtNtB5sNVpxyRwWGcDEhpMzc8g at slot 7", "proof failed for <hash> at slot <n>"},
+	}
+
+	for _, tc := range testCases {
+		if got := normalizeValidationError(tc.validationError); got != tc.want {
+			t.Errorf("normalizeValidationError(%q) = %q, want %q", tc.validationError, got, tc.want)
+		}
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	start := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	summary := newRunSummary(AppConfig{}, start, start.Add(time.Hour))
+
+	summary.addSelected([]Submission{
+		{ID: "1", RawBlock: RawBlock("block")},
+		{ID: "2", RawBlock: RawBlock("block")},
+		{ID: "3"},
+		{ID: "4", RawBlock: RawBlock("block")},
+		{ID: "5", RawBlock: RawBlock("block")},
+	})
+	summary.addBatch(
+		[]Submission{{ID: "1"}, {ID: "2"}, {ID: "3"}},
+		[]Submission{
+			{ID: "1", Submitter: "B62a", BuiltWithCommitSha: "abc", Verified: true},
+			{ID: "3", Submitter: "B62b", BuiltWithCommitSha: "abc", ValidationError: "block at height 1 is too old"},
+			{ID: "6", Submitter: "B62c", BuiltWithCommitSha: "def"},
+		},
+		nil)
+	summary.addReconcileReport(ReconcileReport{Missing: []Submission{{ID: "2"}}, Duplicated: []string{"3"}})
+	summary.addBatch([]Submission{{ID: "4"}, {ID: "5"}}, nil, errors.New("verifier crashed"))
+	summary.finish()
+
+	if summary.Selected != 5 || summary.MissingBlock != 1 || summary.Verified != 1 || summary.Invalid != 2 ||
+		summary.NotReturned != 1 || summary.Duplicated != 1 || summary.Unexpected != 0 || summary.FailedBatches != 1 || summary.FailedSubmissions != 2 {
+		t.Errorf("unexpected totals %+v", summary)
+	}
+	if want := map[string]int{"block at height <n> is too old": 1, "(not verified, no validation error)": 1}; !reflect.DeepEqual(summary.ValidationErrors, want) {
+		t.Errorf("ValidationErrors = %v, want %v", summary.ValidationErrors, want)
+	}
+	if got := summary.BuiltWithCommitSha["abc"]; got == nil || *got != (CommitCounts{Verified: 1, Invalid: 1}) {
+		t.Errorf("BuiltWithCommitSha[abc] = %v, want 1 verified, 1 invalid", got)
+	}
+	if got := summary.BuiltWithCommitSha["def"]; got == nil || *got != (CommitCounts{Verified: 0, Invalid: 1}) {
+		t.Errorf("BuiltWithCommitSha[def] = %v, want 0 verified, 1 invalid", got)
+	}
+	if want := []SubmitterCount{{Submitter: "B62b", Invalid: 1}, {Submitter: "B62c", Invalid: 1}}; !reflect.DeepEqual(summary.TopInvalidSubmitters, want) {
+		t.Errorf("TopInvalidSubmitters = %v, want %v", summary.TopInvalidSubmitters, want)
+	}
+}
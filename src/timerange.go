@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the absolute time formats accepted on the command line.
+// The first one is the layout the program historically accepted.
+var timeLayouts = []string{
+	dateLayout,
+	"2006-01-02 15:04:05-0700",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// parseTimeExpr parses a point in time given on the command line. Accepted are:
+//   - absolute times in one of timeLayouts, e.g. "2024-03-15 13:00:00.0+0000" or "2024-03-15T13:00:00Z"
+//   - Unix epoch seconds, e.g. "1710507600"
+//   - "now", optionally followed by an offset, e.g. "now-10m" or "now+1h"
+//   - a bare offset relative to now, e.g. "-1h"
+//
+// Offsets are Go durations, additionally whole days can be given as e.g. "2d".
+// The result is always in UTC.
+func parseTimeExpr(expr string, now time.Time) (time.Time, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+
+	if strings.HasPrefix(expr, "now") {
+		offset := strings.TrimPrefix(expr, "now")
+		if offset == "" {
+			return now.UTC(), nil
+		}
+		if offset[0] != '+' && offset[0] != '-' {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected now+<duration> or now-<duration>", expr)
+		}
+		d, err := parseDurationExpr(offset)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %w", expr, err)
+		}
+		return now.Add(d).UTC(), nil
+	}
+
+	if expr[0] == '+' || expr[0] == '-' {
+		d, err := parseDurationExpr(expr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %w", expr, err)
+		}
+		return now.Add(d).UTC(), nil
+	}
+
+	if seconds, err := strconv.ParseInt(expr, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, expr); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected %q, RFC3339, Unix seconds or a now-relative expression", expr, dateLayout)
+}
+
+// parseDurationExpr parses a Go duration, additionally accepting whole days such as "2d" or "-1d".
+func parseDurationExpr(expr string) (time.Duration, error) {
+	if strings.HasSuffix(expr, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(expr, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", expr)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(expr)
+}
+
+// parseRangeExpr parses a "<start>..<end>" range, where both ends are time expressions
+// accepted by parseTimeExpr, e.g. "-1h..now".
+func parseRangeExpr(expr string, now time.Time) (startTime, endTime time.Time, err error) {
+	parts := strings.Split(expr, "..")
+	if len(parts) != 2 {
+		return startTime, endTime, fmt.Errorf("invalid range %q, expected <start>..<end>", expr)
+	}
+	if startTime, err = parseTimeExpr(parts[0], now); err != nil {
+		return
+	}
+	endTime, err = parseTimeExpr(parts[1], now)
+	return
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeExpr(t *testing.T) {
+	now := time.Date(2024, 3, 15, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	testCases := []struct {
+		name    string
+		expr    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "legacy layout", expr: "2024-03-15 13:12:12.0+0100", want: time.Date(2024, 3, 15, 12, 12, 12, 0, time.UTC)},
+		{name: "legacy layout without fraction", expr: "2024-03-15 13:12:12+0000", want: time.Date(2024, 3, 15, 13, 12, 12, 0, time.UTC)},
+		{name: "RFC3339", expr: "2024-03-15T13:12:12Z", want: time.Date(2024, 3, 15, 13, 12, 12, 0, time.UTC)},
+		{name: "RFC3339 with offset", expr: "2024-03-15T13:12:12.5-02:00", want: time.Date(2024, 3, 15, 15, 12, 12, 500000000, time.UTC)},
+		{name: "date", expr: "2024-03-15", want: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "epoch seconds", expr: "1710507600", want: time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{name: "now", expr: "now", want: time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{name: "now minus", expr: "now-10m", want: time.Date(2024, 3, 15, 11, 50, 0, 0, time.UTC)},
+		{name: "now plus days", expr: "now+1d", want: time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC)},
+		{name: "bare offset", expr: "-1h", want: time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{name: "now without sign", expr: "now10m", wantErr: true},
+		{name: "invalid offset", expr: "now-10x", wantErr: true},
+		{name: "garbage", expr: "yesterday", wantErr: true},
+		{name: "empty", expr: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTimeExpr(tc.expr, now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseTimeExpr(%q) error = %v, wantErr %v", tc.expr, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !got.Equal(tc.want) || got.Location() != time.UTC {
+				t.Errorf("parseTimeExpr(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRangeOptionsParse(t *testing.T) {
+	now := time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		since      string
+		positional []string
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantErr    bool
+	}{
+		{
+			name:       "two arguments",
+			positional: []string{"2024-03-15 12:00:00.0+0000", "2024-03-15T12:30:00Z"},
+			wantStart:  time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+			wantEnd:    time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "range expression",
+			positional: []string{"-1h..now"},
+			wantStart:  time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+			wantEnd:    now,
+		},
+		{
+			name:      "since",
+			since:     "30m",
+			wantStart: time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC),
+			wantEnd:   now,
+		},
+		{name: "since with arguments", since: "30m", positional: []string{"-1h..now"}, wantErr: true},
+		{name: "negative since", since: "-30m", wantErr: true},
+		{name: "reversed range", positional: []string{"now..now-1h"}, wantErr: true},
+		{name: "no arguments", wantErr: true},
+		{name: "too many arguments", positional: []string{"now", "now", "now"}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := rangeOptions{since: tc.since}
+			start, end, err := opts.parse(tc.positional, now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && (!start.Equal(tc.wantStart) || !end.Equal(tc.wantEnd)) {
+				t.Errorf("parse() = (%v, %v), want (%v, %v)", start, end, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// Verifier verifies batches of submissions. It returns one result per submission it
+// could verify, in any order; reconcileBatch matches the results to the batch.
+type Verifier interface {
+	Verify(ctx context.Context, batch []Submission) ([]Submission, error)
+	// Version returns the version of the verifier, e.g. the commit it was built from.
+	Version(ctx context.Context) (string, error)
+}
+
+// Verifier implementations selectable with DELEGATION_VERIFIER.
+const (
+	// VerifierSubprocess runs the delegation-verify binary at DELEGATION_VERIFY_BIN_PATH.
+	VerifierSubprocess = "SUBPROCESS"
+	// VerifierFake verifies submissions in-process with the rules of FakeVerifier.
+	VerifierFake = "FAKE"
+)
+
+var validVerifiers = []string{VerifierSubprocess, VerifierFake}
+
+// NewVerifier creates the verifier selected by cfg.Verifier.
+func NewVerifier(cfg AppConfig, log *logging.ZapEventLogger) (Verifier, error) {
+	switch cfg.Verifier {
+	case VerifierSubprocess, "":
+		return NewSubprocessVerifier(cfg, log)
+	case VerifierFake:
+		log.Warn("Note! Using the fake verifier. Submissions are not actually verified.")
+		return FakeVerifier{}, nil
+	default:
+		return nil, validateVerifier(cfg.Verifier)
+	}
+}
+
+func validateVerifier(verifier string) error {
+	for _, valid := range validVerifiers {
+		if verifier == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid verifier %s, valid options are %v", verifier, validVerifiers)
+}
+
+// detectVerifierVersion determines the version of verifier and checks it against
+// VerifierVersionPattern. A version that can not be detected is only an error if it
+// is required, i.e. if a pattern is configured or versions are stored.
+func detectVerifierVersion(ctx context.Context, verifier Verifier, cfg AppConfig, log *logging.ZapEventLogger) (string, error) {
+	version, err := verifier.Version(ctx)
+	if err != nil {
+		if cfg.VerifierVersionPattern != "" || cfg.StoreVerifierVersion {
+			return "", fmt.Errorf("error detecting delegation verifier version: %w", err)
+		}
+		log.Warnf("Could not detect delegation verifier version: %v", err)
+		return "", nil
+	}
+	log.Infof("Using delegation verifier version: %s", version)
+
+	if cfg.VerifierVersionPattern != "" {
+		pattern, err := regexp.Compile(cfg.VerifierVersionPattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid DELEGATION_VERIFY_VERSION_PATTERN: %w", err)
+		}
+		if !pattern.MatchString(version) {
+			return "", fmt.Errorf("delegation verifier version %q is not compatible, expected a version matching %q", version, cfg.VerifierVersionPattern)
+		}
+	}
+	return version, nil
+}
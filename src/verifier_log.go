@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+const (
+	// stderrTailLines is the number of stderr lines of a failed verifier run included in the error.
+	stderrTailLines = 20
+	// maxTailLineLength truncates long stderr lines kept for the error message.
+	maxTailLineLength = 1024
+)
+
+// verifierLogEntry is a structured log line as written by delegation-verify, which uses
+// the Mina JSON log format.
+type verifierLogEntry struct {
+	Timestamp string                 `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Source    map[string]interface{} `json:"source"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// parseVerifierLogLine parses a JSON log line of delegation-verify. It returns false
+// if the line is not a JSON object with a message.
+func parseVerifierLogLine(line []byte) (verifierLogEntry, bool) {
+	var entry verifierLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Message == "" {
+		return verifierLogEntry{}, false
+	}
+	return entry, true
+}
+
+// logVerifierLine re-emits a line delegation-verify wrote to stream ("stdout" or "stderr")
+// through log. JSON log lines keep their level, timestamp and metadata; other lines are logged
+// as is, at info level for stdout and warning level for stderr.
+func logVerifierLine(log *logging.ZapEventLogger, verifier, stream string, line []byte) {
+	if len(strings.TrimSpace(string(line))) == 0 {
+		return
+	}
+
+	entry, ok := parseVerifierLogLine(line)
+	if !ok {
+		if stream == "stderr" {
+			log.Warnw("delegation-verify: "+string(line), "verifier", verifier, "stream", stream)
+		} else {
+			log.Infow("delegation-verify: "+string(line), "verifier", verifier, "stream", stream)
+		}
+		return
+	}
+
+	keysAndValues := []interface{}{"verifier", verifier, "stream", stream}
+	if entry.Timestamp != "" {
+		keysAndValues = append(keysAndValues, "verifier_timestamp", entry.Timestamp)
+	}
+	if len(entry.Metadata) > 0 {
+		keysAndValues = append(keysAndValues, "metadata", entry.Metadata)
+	}
+	if len(entry.Source) > 0 {
+		keysAndValues = append(keysAndValues, "source", entry.Source)
+	}
+
+	msg := "delegation-verify: " + entry.Message
+	switch strings.ToLower(entry.Level) {
+	case "spam", "trace", "debug":
+		log.Debugw(msg, keysAndValues...)
+	case "warn", "warning":
+		log.Warnw(msg, keysAndValues...)
+	case "error", "fatal", "faulty_peer":
+		log.Errorw(msg, keysAndValues...)
+	default:
+		log.Infow(msg, keysAndValues...)
+	}
+}
+
+// lineTail keeps the last lines written to it.
+type lineTail struct {
+	lines []string
+	max   int
+}
+
+func newLineTail(max int) *lineTail {
+	return &lineTail{max: max}
+}
+
+func (t *lineTail) add(line []byte) {
+	s := string(line)
+	if len(s) > maxTailLineLength {
+		s = s[:maxTailLineLength] + "..."
+	}
+	if len(t.lines) == t.max {
+		t.lines = t.lines[1:]
+	}
+	t.lines = append(t.lines, s)
+}
+
+func (t *lineTail) String() string {
+	return strings.Join(t.lines, "\n")
+}
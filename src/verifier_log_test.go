@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVerifierLogLine(t *testing.T) {
+	testCases := []struct {
+		name   string
+		line   string
+		want   verifierLogEntry
+		wantOK bool
+	}{
+		{
+			name: "mina json log",
+			line: `{"timestamp":"2024-03-11 10:00:00.000000Z","level":"Warn","source":{"module":"Delegation_verify"},"message":"Block $hash not found","metadata":{"hash":"3NK"}}`,
+			want: verifierLogEntry{
+				Timestamp: "2024-03-11 10:00:00.000000Z",
+				Level:     "Warn",
+				Message:   "Block $hash not found",
+				Source:    map[string]interface{}{"module": "Delegation_verify"},
+				Metadata:  map[string]interface{}{"hash": "3NK"},
+			},
+			wantOK: true,
+		},
+		{name: "plain text", line: "Fatal error: out of memory"},
+		{name: "json without message", line: `{"level":"Info"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseVerifierLogLine([]byte(tc.line))
+			if ok != tc.wantOK || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseVerifierLogLine(%q) = %+v, %v, want %+v, %v", tc.line, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestLineTail(t *testing.T) {
+	tail := newLineTail(2)
+	for _, line := range []string{"one", "two", "three", strings.Repeat("x", maxTailLineLength+1)} {
+		tail.add([]byte(line))
+	}
+	want := "three\n" + strings.Repeat("x", maxTailLineLength) + "..."
+	if got := tail.String(); got != want {
+		t.Errorf("lineTail.String() = %q, want %q", got, want)
+	}
+}
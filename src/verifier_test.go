@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+// versionVerifier is a Verifier reporting a fixed version.
+type versionVerifier struct {
+	FakeVerifier
+	version string
+	err     error
+}
+
+func (v versionVerifier) Version(ctx context.Context) (string, error) {
+	return v.version, v.err
+}
+
+func TestDetectVerifierVersion(t *testing.T) {
+	detectErr := errors.New("unknown flag -version")
+	testCases := []struct {
+		name     string
+		verifier versionVerifier
+		cfg      AppConfig
+		want     string
+		wantErr  bool
+	}{
+		{name: "detected", verifier: versionVerifier{version: "Commit abc123 on branch compatible"}, want: "Commit abc123 on branch compatible"},
+		{name: "compatible", verifier: versionVerifier{version: "Commit abc123 on branch compatible"}, cfg: AppConfig{VerifierVersionPattern: "abc123"}, want: "Commit abc123 on branch compatible"},
+		{name: "incompatible", verifier: versionVerifier{version: "Commit def456 on branch develop"}, cfg: AppConfig{VerifierVersionPattern: "^Commit abc123"}, wantErr: true},
+		{name: "undetected", verifier: versionVerifier{err: detectErr}, want: ""},
+		{name: "undetected with pattern", verifier: versionVerifier{err: detectErr}, cfg: AppConfig{VerifierVersionPattern: "abc123"}, wantErr: true},
+		{name: "undetected with stored version", verifier: versionVerifier{err: detectErr}, cfg: AppConfig{StoreVerifierVersion: true}, wantErr: true},
+	}
+
+	log := logging.Logger("test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := detectVerifierVersion(context.Background(), tc.verifier, tc.cfg, log)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("detectVerifierVersion() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("detectVerifierVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}